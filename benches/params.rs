@@ -0,0 +1,42 @@
+//! Cost of the generators per request, created from scratch or taken from the shared
+//! parameters.
+#![feature(test)]
+
+extern crate test;
+
+use bulletproofs::{BulletproofGens, PedersenGens};
+use dusk_blindbidproof::blindbid::{generators_capacity, params};
+use test::Bencher;
+
+fn fresh(b: &mut Bencher, list_len: usize) {
+    let capacity = generators_capacity(list_len).unwrap();
+
+    b.iter(|| (PedersenGens::default(), BulletproofGens::new(capacity, 1)));
+}
+
+fn cached(b: &mut Bencher, list_len: usize) {
+    let capacity = generators_capacity(list_len).unwrap();
+    params(capacity);
+
+    b.iter(|| params(capacity));
+}
+
+#[bench]
+fn fresh_params_64_bids(b: &mut Bencher) {
+    fresh(b, 64);
+}
+
+#[bench]
+fn cached_params_64_bids(b: &mut Bencher) {
+    cached(b, 64);
+}
+
+#[bench]
+fn fresh_params_1024_bids(b: &mut Bencher) {
+    fresh(b, 1024);
+}
+
+#[bench]
+fn cached_params_1024_bids(b: &mut Bencher) {
+    cached(b, 1024);
+}
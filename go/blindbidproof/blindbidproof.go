@@ -0,0 +1,403 @@
+// Package blindbidproof is the Go client of the dusk-blindbidproof daemon.
+//
+// Every request is a single dusk-tlv frame sent over the daemon unix socket.
+// The frame starts with the request ID and the timeout in milliseconds, as
+// little-endian uint64s, followed by the operation code; the remaining bytes
+// are the request variables in the order expected by
+// `Proof::try_from_reader_variables` and `Verify::try_from_reader_variables`,
+// the proof context and message being the last ones.
+// The response frame starts with the ID of its request.
+//
+// The response to a prove request holds the proof, followed by the blindings
+// of its commitments.
+package blindbidproof
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gitlab.dusk.network/dusk-core/blindbidproof/go/tlv"
+)
+
+const (
+	opProve       byte = 1
+	opVerify      byte = 2
+	opVerifyBatch byte = 3
+	opSubmitProve byte = 4
+	opJobStatus   byte = 5
+	opCancelJob   byte = 6
+	opHello       byte = 7
+	opPing        byte = 8
+)
+
+// SocketPath is the unix socket the daemon is bound to. It defaults to the
+// daemon's own default bind path.
+var SocketPath = filepath.Join(os.TempDir(), "dusk-uds-blindbid")
+
+// Scalar is the canonical little-endian encoding of a ristretto scalar. The
+// daemon rejects the encodings of values not lower than the order of the field
+// with StatusNonCanonicalScalar.
+type Scalar [32]byte
+
+// ProveRequest holds the variables of a blind bid proof.
+type ProveRequest struct {
+	D Scalar
+	K Scalar
+	// Y is still sent, but no longer committed to by the proofs: any
+	// canonical scalar is accepted.
+	Y       Scalar
+	YInv    Scalar
+	Q       Scalar
+	ZImg    Scalar
+	Seed    Scalar
+	PubList []Scalar
+	// Toggle is the index of the bid X in PubList.
+	Toggle uint64
+	// Context is bound to the proof, such as the chain ID, round and step it
+	// is made for. The proof only verifies with the same Context.
+	Context []byte
+	// Message, if not nil, is signed by the proof, such as the hash of the
+	// block the proof comes with. The proof only verifies with the same
+	// Message, and a nil Message differs from an empty one.
+	Message []byte
+	// Blindings, if not nil, are the blindings of the commitments of the
+	// proof, the daemon picking random ones otherwise. The blinding of D is
+	// the one of the commitment the bid was locked with, see
+	// VerifyRequest.DCommitment.
+	Blindings *Blindings
+}
+
+// Blindings are the blinding factors of the commitments of a proof.
+type Blindings struct {
+	D    Scalar
+	K    Scalar
+	YInv Scalar
+}
+
+// VerifyRequest holds the proof and the public variables to verify it against.
+type VerifyRequest struct {
+	Proof   *Proof
+	Score   Scalar
+	ZImg    Scalar
+	Seed    Scalar
+	PubList []Scalar
+	// Context is the context of the ProveRequest of the proof.
+	Context []byte
+	// Message is the message of the ProveRequest of the proof.
+	Message []byte
+	// DCommitment, if not nil, is the commitment the bid was locked with.
+	// The proof is only valid if its commitment to D is the same.
+	DCommitment []byte
+}
+
+// The layouts of a proof, as `ProofVersion`.
+const (
+	// ProofV1 commits to Y and to every toggle of the bid list in TC. The
+	// daemon still verifies ProofV1 proofs, which are bound to no Context or
+	// Message.
+	ProofV1 byte = 1
+	// ProofV2 commits to D, K and YInv only, and has no TC. It is encoded in
+	// an envelope starting with proofMagic and the version. The daemon creates
+	// ProofV2 proofs.
+	ProofV2 byte = 2
+)
+
+// proofMagic leads the header of a proof envelope, followed by the version.
+const proofMagic = "dbbp"
+
+// Proof is a blind bid proof as returned by the daemon.
+type Proof struct {
+	// Version is the layout of the proof, ProofV1 if zero.
+	Version byte
+	// ParamSetID is the parameter set the proof was made with, as reported by
+	// Hello. ProofV1 proofs do not carry it.
+	ParamSetID [32]byte
+	// ListDigest is the digest of the bid list, or of the bid tree, the proof
+	// was made against. ProofV1 proofs do not carry it.
+	ListDigest [32]byte
+	// Proof is the serialized R1CS proof.
+	Proof       []byte
+	Commitments [][]byte
+	TC          [][]byte
+}
+
+// MarshalBinary encodes the proof the same way `TryInto<Vec<u8>> for Proof` does.
+func (p *Proof) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+
+	w := tlv.NewWriter(&buf)
+	switch p.Version {
+	case 0, ProofV1:
+	case ProofV2:
+		header := append([]byte(proofMagic), p.Version)
+		for _, frame := range [][]byte{header, p.ParamSetID[:], p.ListDigest[:]} {
+			if err := w.Write(frame); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("blindbidproof: unknown proof version %d", p.Version)
+	}
+
+	if err := w.Write(p.Proof); err != nil {
+		return nil, err
+	}
+
+	if err := w.WriteList(p.Commitments); err != nil {
+		return nil, err
+	}
+
+	if p.Version == ProofV2 {
+		return buf.Bytes(), nil
+	}
+
+	if err := w.WriteList(p.TC); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a proof encoded by `TryInto<Vec<u8>> for Proof`, of
+// either version.
+func (p *Proof) UnmarshalBinary(data []byte) error {
+	r := tlv.NewReader(bytes.NewReader(data))
+
+	proof, err := r.Next()
+	if err != nil {
+		return fmt.Errorf("blindbidproof: reading the proof: %v", err)
+	}
+
+	// A ProofV1 proof starts with the R1CS proof, never as short as the header
+	var paramSetID, listDigest [32]byte
+	version := ProofV1
+	if len(proof) == len(proofMagic)+1 && bytes.HasPrefix(proof, []byte(proofMagic)) {
+		if version = proof[len(proofMagic)]; version != ProofV2 {
+			return fmt.Errorf("blindbidproof: unknown proof version %d", version)
+		}
+
+		for _, field := range []struct {
+			name string
+			dst  *[32]byte
+		}{{"the parameter set ID", &paramSetID}, {"the list digest", &listDigest}} {
+			b, err := r.Next()
+			if err != nil {
+				return fmt.Errorf("blindbidproof: reading %s: %v", field.name, err)
+			}
+
+			if len(b) != len(field.dst) {
+				return fmt.Errorf("blindbidproof: %s has %d bytes", field.name, len(b))
+			}
+
+			copy(field.dst[:], b)
+		}
+
+		if proof, err = r.Next(); err != nil {
+			return fmt.Errorf("blindbidproof: reading the proof: %v", err)
+		}
+	}
+
+	commitments, err := r.ReadList()
+	if err != nil {
+		return fmt.Errorf("blindbidproof: reading the commitments: %v", err)
+	}
+
+	var tc [][]byte
+	if version == ProofV1 {
+		if tc, err = r.ReadList(); err != nil {
+			return fmt.Errorf("blindbidproof: reading t_c: %v", err)
+		}
+	}
+
+	p.Version, p.ParamSetID, p.ListDigest = version, paramSetID, listDigest
+	p.Proof, p.Commitments, p.TC = proof, commitments, tc
+	return nil
+}
+
+// Prove asks the daemon for a proof of the provided bid, through DefaultClient.
+func Prove(ctx context.Context, req ProveRequest) (*Proof, *Blindings, error) {
+	return DefaultClient.Prove(ctx, req)
+}
+
+// Verify asks the daemon to verify a proof, through DefaultClient.
+func Verify(ctx context.Context, req VerifyRequest) (bool, error) {
+	return DefaultClient.Verify(ctx, req)
+}
+
+// VerifyBatch asks the daemon to verify several proofs at once, through
+// DefaultClient.
+func VerifyBatch(ctx context.Context, reqs []VerifyRequest) (bool, []bool, error) {
+	return DefaultClient.VerifyBatch(ctx, reqs)
+}
+
+// Prove asks the daemon for a proof of the provided bid, returning the proof
+// and the blindings of its commitments.
+func (c *Client) Prove(ctx context.Context, req ProveRequest) (*Proof, *Blindings, error) {
+	resp, err := c.roundTrip(ctx, append([]byte{opProve}, req.encode()...))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return parseProve(resp)
+}
+
+// Verify asks the daemon to verify a proof. A nil error with a false result
+// means the daemon rejected the proof, while a request it could not process is
+// reported as an *Error.
+func (c *Client) Verify(ctx context.Context, req VerifyRequest) (bool, error) {
+	payload, err := req.encode()
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := c.roundTrip(ctx, append([]byte{opVerify}, payload...))
+	if err != nil {
+		return false, err
+	}
+
+	if len(resp) != 1 {
+		return false, fmt.Errorf("blindbidproof: unexpected verify response of %d bytes", len(resp))
+	}
+
+	return resp[0] == 0x01, nil
+}
+
+// VerifyBatch asks the daemon to verify several proofs at once. It returns
+// whether all of them are valid, and the result of every proof in the order
+// of reqs.
+func (c *Client) VerifyBatch(ctx context.Context, reqs []VerifyRequest) (bool, []bool, error) {
+	payloads := make([][]byte, len(reqs))
+	for i := range reqs {
+		payload, err := reqs[i].encode()
+		if err != nil {
+			return false, nil, fmt.Errorf("blindbidproof: batch entry %d: %v", i, err)
+		}
+
+		payloads[i] = payload
+	}
+
+	var buf bytes.Buffer
+
+	buf.WriteByte(opVerifyBatch)
+	tlv.NewWriter(&buf).WriteList(payloads)
+
+	resp, err := c.roundTrip(ctx, buf.Bytes())
+	if err != nil {
+		return false, nil, err
+	}
+
+	if len(resp) != len(reqs)+1 {
+		return false, nil, fmt.Errorf("blindbidproof: unexpected batch response of %d bytes for %d proofs", len(resp), len(reqs))
+	}
+
+	results := make([]bool, len(reqs))
+	for i := range results {
+		results[i] = resp[i+1] == 0x01
+	}
+
+	return resp[0] == 0x01, results, nil
+}
+
+// encode returns the variables of the request, without the operation code.
+func (req *ProveRequest) encode() []byte {
+	var buf bytes.Buffer
+
+	w := tlv.NewWriter(&buf)
+	for _, s := range []Scalar{req.D, req.K, req.Y, req.YInv, req.Q, req.ZImg, req.Seed} {
+		w.WriteScalar(s)
+	}
+	w.WriteList(scalars(req.PubList))
+	w.WriteUint64(req.Toggle)
+	w.Write(req.Context)
+	if req.Message != nil {
+		w.Write(req.Message)
+	}
+	if req.Blindings != nil {
+		for _, s := range []Scalar{req.Blindings.D, req.Blindings.K, req.Blindings.YInv} {
+			w.WriteScalar(s)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// encode returns the variables of the request, without the operation code.
+func (req *VerifyRequest) encode() ([]byte, error) {
+	if req.Proof == nil {
+		return nil, errors.New("blindbidproof: no proof provided")
+	}
+
+	if req.DCommitment != nil && len(req.DCommitment) != 32 {
+		return nil, fmt.Errorf("blindbidproof: the d commitment has %d bytes", len(req.DCommitment))
+	}
+
+	proof, err := req.Proof.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	w := tlv.NewWriter(&buf)
+	w.Write(proof)
+	for _, s := range []Scalar{req.Score, req.ZImg, req.Seed} {
+		w.WriteScalar(s)
+	}
+	w.WriteList(scalars(req.PubList))
+	w.Write(req.Context)
+
+	// With a d commitment, the message has a fixed slot after its presence
+	switch {
+	case req.DCommitment != nil:
+		present := byte(0x00)
+		if req.Message != nil {
+			present = 0x01
+		}
+
+		w.Write([]byte{present})
+		w.Write(req.Message)
+		w.Write(req.DCommitment)
+	case req.Message != nil:
+		w.Write(req.Message)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// parseProve decodes the response to a prove request: the proof, followed by
+// the blindings of d, k and y^-1.
+func parseProve(resp []byte) (*Proof, *Blindings, error) {
+	r := tlv.NewReader(bytes.NewReader(resp))
+
+	b, err := r.Next()
+	if err != nil {
+		return nil, nil, fmt.Errorf("blindbidproof: reading the proof: %v", err)
+	}
+
+	proof := new(Proof)
+	if err := proof.UnmarshalBinary(b); err != nil {
+		return nil, nil, err
+	}
+
+	blindings := new(Blindings)
+	for _, dst := range []*Scalar{&blindings.D, &blindings.K, &blindings.YInv} {
+		if *dst, err = r.ReadScalar(); err != nil {
+			return nil, nil, fmt.Errorf("blindbidproof: reading the blindings: %v", err)
+		}
+	}
+
+	return proof, blindings, nil
+}
+
+func scalars(list []Scalar) [][]byte {
+	items := make([][]byte, len(list))
+	for i := range list {
+		items[i] = list[i][:]
+	}
+
+	return items
+}
@@ -0,0 +1,418 @@
+package blindbidproof
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"gitlab.dusk.network/dusk-core/blindbidproof/go/mimc"
+	"gitlab.dusk.network/dusk-core/blindbidproof/go/tlv"
+)
+
+// The bid proved by the tests sits at index 5 of the public list.
+var (
+	fixtureD    = Scalar(mimc.NewScalar(20000))
+	fixtureK    = Scalar(mimc.NewScalar(0x1234567890abcdef))
+	fixtureSeed = Scalar(mimc.NewScalar(0xdeadbeef))
+	fixtureCtx  = []byte("testnet, round 42, step 3")
+	fixtureMsg  = []byte("candidate block hash")
+	fixture     = mimc.Derive(mimc.Scalar(fixtureD), mimc.Scalar(fixtureK), mimc.Scalar(fixtureSeed))
+
+	fixturePubList = []Scalar{
+		scalar("faa4114eb8416576e760407051b0aecb33c4de9b1d19b22cb58371feb4e94e0b"),
+		scalar("cc05bf39abc57400419d5eea1b8a1de971a3f1a62ada04d253832b9a87bd7607"),
+		scalar("8ab7c13a7bf6f223102daf769a8af1a200b4d571807ecf1b55c089a04c830003"),
+		scalar("15ca49938742337d81124d74d518551ac2f2f7b24b78b7eef7800c938e758904"),
+		scalar("df9c16c70857568c5d13c7e823737f8abad46872210c722c018f4e5f217c9303"),
+		Scalar(fixture.X),
+		scalar("9569ebcce60e2c0ee739637c88df5d9970a389e562b390f2b80aca6be5928f0a"),
+		scalar("73cb355f08f2ef08b24e900810a727c587c8642a01125196ec09118f99321d07"),
+	}
+)
+
+func scalar(s string) Scalar {
+	var sc Scalar
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+
+	copy(sc[:], b)
+	return sc
+}
+
+func proveRequest() ProveRequest {
+	return ProveRequest{
+		D:       fixtureD,
+		K:       fixtureK,
+		Y:       Scalar(fixture.Y),
+		YInv:    Scalar(fixture.YInv),
+		Q:       Scalar(fixture.Q),
+		ZImg:    Scalar(fixture.Z),
+		Seed:    fixtureSeed,
+		PubList: fixturePubList,
+		Toggle:  5,
+		Context: fixtureCtx,
+		Message: fixtureMsg,
+	}
+}
+
+func verifyRequest(proof *Proof) VerifyRequest {
+	return VerifyRequest{
+		Proof:   proof,
+		Score:   Scalar(fixture.Q),
+		ZImg:    Scalar(fixture.Z),
+		Seed:    fixtureSeed,
+		PubList: fixturePubList,
+		Context: fixtureCtx,
+		Message: fixtureMsg,
+	}
+}
+
+// requireDaemon skips the test when the daemon is not listening on SocketPath.
+func requireDaemon(tb testing.TB) {
+	conn, err := net.Dial("unix", SocketPath)
+	if err != nil {
+		tb.Skipf("blind bid daemon not reachable at %s: %v", SocketPath, err)
+	}
+
+	conn.Close()
+}
+
+func TestProofBinary(t *testing.T) {
+	proofs := []*Proof{
+		{
+			Version:     ProofV1,
+			Proof:       bytes.Repeat([]byte{0xaa}, 300),
+			Commitments: [][]byte{fixtureD[:], fixtureK[:], fixture.Y[:], fixture.YInv[:]},
+			TC:          [][]byte{fixture.Q[:], fixture.Z[:]},
+		},
+		{
+			Version:     ProofV2,
+			ParamSetID:  fixtureSeed,
+			ListDigest:  fixture.X,
+			Proof:       bytes.Repeat([]byte{0xaa}, 300),
+			Commitments: [][]byte{fixtureD[:], fixtureK[:], fixture.YInv[:]},
+		},
+	}
+
+	for _, proof := range proofs {
+		b, err := proof.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded := new(Proof)
+		if err := decoded.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(proof, decoded) {
+			t.Fatalf("decoded v%d proof differs from the original", proof.Version)
+		}
+
+		if err := decoded.UnmarshalBinary(b[:len(b)-1]); err == nil {
+			t.Fatalf("a truncated v%d proof was decoded", proof.Version)
+		}
+	}
+
+	// The envelope header leads the v2 layout only
+	b, _ := proofs[1].MarshalBinary()
+	if !bytes.HasPrefix(b, []byte("\x01\x05dbbp\x02\x01\x20")) {
+		t.Fatalf("unexpected v2 header %x", b[:9])
+	}
+
+	b[6] = 3
+	if err := new(Proof).UnmarshalBinary(b); err == nil {
+		t.Fatalf("an unknown proof version was decoded")
+	}
+
+	if _, err := (&Proof{Version: 3}).MarshalBinary(); err == nil {
+		t.Fatalf("an unknown proof version was encoded")
+	}
+}
+
+func TestRequestBindings(t *testing.T) {
+	blindings := &Blindings{D: fixtureSeed, K: fixtureK, YInv: fixtureD}
+
+	req := proveRequest()
+	req.Blindings = blindings
+
+	frames, err := tlv.Split(req.encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The blindings follow the message
+	tail := frames[len(frames)-4:]
+	if !bytes.Equal(tail[0], fixtureMsg) || !bytes.Equal(tail[1], fixtureSeed[:]) || !bytes.Equal(tail[3], fixtureD[:]) {
+		t.Fatalf("unexpected trailing frames %x", tail)
+	}
+
+	proof := &Proof{Version: ProofV2, Proof: bytes.Repeat([]byte{0xaa}, 32), Commitments: [][]byte{fixtureD[:]}}
+	b, err := proof.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := tlv.Encode(b)
+	for _, s := range []Scalar{blindings.D, blindings.K, blindings.YInv} {
+		resp = append(resp, tlv.Encode(s[:])...)
+	}
+
+	decoded, decodedBlindings, err := parseProve(resp)
+	if err != nil || !reflect.DeepEqual(decoded, proof) || *decodedBlindings != *blindings {
+		t.Fatalf("unexpected prove response %+v, %+v: %v", decoded, decodedBlindings, err)
+	}
+
+	if _, _, err := parseProve(tlv.Encode(b)); err == nil {
+		t.Fatalf("a prove response without the blindings was decoded")
+	}
+
+	// The expected d commitment follows the message, and needs one
+	vreq := verifyRequest(proof)
+	vreq.DCommitment = fixtureK[:]
+
+	payload, err := vreq.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if frames, err = tlv.Split(payload); err != nil {
+		t.Fatal(err)
+	}
+
+	// The message has a fixed slot after its presence
+	tail = frames[len(frames)-3:]
+	if !reflect.DeepEqual(tail, [][]byte{{0x01}, fixtureMsg, fixtureK[:]}) {
+		t.Fatalf("unexpected trailing frames %x", tail)
+	}
+
+	// No message differs from an empty one
+	for _, msg := range [][]byte{nil, {}} {
+		vreq.Message = msg
+
+		if payload, err = vreq.encode(); err != nil {
+			t.Fatal(err)
+		}
+
+		if frames, err = tlv.Split(payload); err != nil {
+			t.Fatal(err)
+		}
+
+		present := []byte{0x00}
+		if msg != nil {
+			present[0] = 0x01
+		}
+
+		tail = frames[len(frames)-3:]
+		if !reflect.DeepEqual(tail, [][]byte{present, {}, fixtureK[:]}) {
+			t.Fatalf("unexpected trailing frames %x for the message %#v", tail, msg)
+		}
+	}
+
+	vreq = verifyRequest(proof)
+	vreq.DCommitment = fixtureK[:31]
+	if _, err := vreq.encode(); err == nil {
+		t.Fatalf("a d commitment of 31 bytes was encoded")
+	}
+}
+
+func TestHello(t *testing.T) {
+	requireDaemon(t)
+
+	caps, err := Hello(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The proof envelope is part of the fourth version
+	if caps.ProtocolVersion != 4 || caps.MaxListLen < uint64(len(fixturePubList)) {
+		t.Fatalf("unexpected capabilities %+v", caps)
+	}
+
+	if err := Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProveVerify(t *testing.T) {
+	requireDaemon(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
+	defer cancel()
+
+	proof, _, err := Prove(ctx, proveRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if proof.Version != ProofV2 || len(proof.Commitments) != 3 || len(proof.TC) != 0 {
+		t.Fatalf("unexpected proof layout: v%d, %d commitments, %d t_c", proof.Version, len(proof.Commitments), len(proof.TC))
+	}
+
+	caps, err := Hello(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if proof.ParamSetID != caps.ParamSetID {
+		t.Fatalf("the proof was made with the parameter set %x, the daemon has %x", proof.ParamSetID, caps.ParamSetID)
+	}
+
+	ok, err := Verify(ctx, verifyRequest(proof))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ok {
+		t.Fatalf("a valid proof was rejected")
+	}
+
+	// The same proof must not verify against a different score
+	req := verifyRequest(proof)
+	req.Score = fixtureD
+
+	ok, err = Verify(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok {
+		t.Fatalf("a proof was accepted for the wrong score")
+	}
+
+	// Nor in a different context
+	req = verifyRequest(proof)
+	req.Context = []byte("testnet, round 43, step 3")
+
+	ok, err = Verify(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok {
+		t.Fatalf("a proof was accepted in the wrong context")
+	}
+
+	// Nor for a different message
+	req = verifyRequest(proof)
+	req.Message = nil
+
+	ok, err = Verify(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok {
+		t.Fatalf("a proof was accepted without its message")
+	}
+}
+
+func TestDCommitment(t *testing.T) {
+	requireDaemon(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
+	defer cancel()
+
+	// With and without a message, which has its own slot then
+	for _, msg := range [][]byte{fixtureMsg, nil} {
+		req := proveRequest()
+		req.Message = msg
+		req.Blindings = &Blindings{D: fixtureSeed, K: fixtureK, YInv: fixtureD}
+
+		proof, blindings, err := Prove(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if *blindings != *req.Blindings {
+			t.Fatalf("the proof was made with the blindings %+v instead of %+v", blindings, req.Blindings)
+		}
+
+		// The first commitment of the proof is the one to D
+		locked := verifyRequest(proof)
+		locked.Message = msg
+		locked.DCommitment = proof.Commitments[0]
+
+		wrong := locked
+		wrong.DCommitment = proof.Commitments[1]
+
+		ok, err := Verify(ctx, locked)
+		if err != nil || !ok {
+			t.Fatalf("a proof was rejected for its own d commitment: %v", err)
+		}
+
+		ok, err = Verify(ctx, wrong)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok {
+			t.Fatalf("a proof was accepted for the wrong d commitment")
+		}
+
+		_, results, err := VerifyBatch(ctx, []VerifyRequest{locked, wrong})
+		if err != nil || !reflect.DeepEqual(results, []bool{true, false}) {
+			t.Fatalf("unexpected batch results %v: %v", results, err)
+		}
+	}
+}
+
+func TestVerifyBatch(t *testing.T) {
+	requireDaemon(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
+	defer cancel()
+
+	proof, _, err := Prove(ctx, proveRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wrong := verifyRequest(proof)
+	wrong.Score = fixtureD
+
+	reqs := []VerifyRequest{verifyRequest(proof), verifyRequest(proof)}
+
+	ok, results, err := VerifyBatch(ctx, reqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ok || !results[0] || !results[1] {
+		t.Fatalf("a valid batch was rejected: %v", results)
+	}
+
+	ok, results, err = VerifyBatch(ctx, append(reqs, wrong))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok || !reflect.DeepEqual(results, []bool{true, true, false}) {
+		t.Fatalf("unexpected batch results %v", results)
+	}
+}
+
+func BenchmarkProveVerify(b *testing.B) {
+	requireDaemon(b)
+
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		proof, _, err := Prove(ctx, proveRequest())
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if _, err := Verify(ctx, verifyRequest(proof)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
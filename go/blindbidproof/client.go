@@ -0,0 +1,352 @@
+package blindbidproof
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gitlab.dusk.network/dusk-core/blindbidproof/go/tlv"
+)
+
+// ErrNoResponse is returned when the daemon closes the connection without
+// answering.
+var ErrNoResponse = errors.New("blindbidproof: the daemon closed the connection without a response")
+
+// DefaultClient is the Client used by the package level functions.
+var DefaultClient = &Client{}
+
+// Client sends requests to the daemon over a single connection.
+//
+// Every request carries an ID chosen by the Client, and the daemon answers the
+// requests of a connection concurrently, in the order they complete. A
+// Client is safe for concurrent use: concurrent requests share the
+// connection, and the responses are matched back to their callers by ID.
+//
+// Every new connection starts with the hello operation, and a daemon that is
+// not compatible with the Client is refused with an error wrapping
+// ErrIncompatible.
+type Client struct {
+	// Path is the daemon socket. If empty, SocketPath is used.
+	Path string
+	// ParamSetID, if set, is the only parameter set accepted from the daemon.
+	ParamSetID [32]byte
+
+	lastID uint64
+
+	mu   sync.Mutex
+	conn *muxConn
+}
+
+// NewClient returns a Client for the daemon bound to path.
+func NewClient(path string) *Client {
+	return &Client{Path: path}
+}
+
+// Close closes the connection, failing the pending requests.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	conn := c.conn
+	c.conn = nil
+	c.mu.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+
+	return conn.close(ErrNoResponse)
+}
+
+// roundTrip sends a request and returns the result of its response.
+func (c *Client) roundTrip(ctx context.Context, request []byte) ([]byte, error) {
+	conn, reused, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id := atomic.AddUint64(&c.lastID, 1)
+	resp, err := conn.do(ctx, id, request)
+
+	// The daemon closes the connections that stay idle too long. Only the
+	// requests that can safely run twice are sent again, since the first one
+	// may have reached the daemon.
+	if reused && idempotent(request) && ctx.Err() == nil && (err == ErrNoResponse || isClosedByPeer(err)) {
+		conn, _, err = c.connect(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err = conn.do(ctx, id, request)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parseResponse(resp)
+}
+
+// connect returns the open connection, or a new one. The boolean reports
+// whether the connection was already open.
+func (c *Client) connect(ctx context.Context) (*muxConn, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != nil && c.conn.alive() {
+		return c.conn, true, nil
+	}
+
+	path := c.Path
+	if path == "" {
+		path = SocketPath
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	mux := newMuxConn(conn)
+	if err := c.handshake(ctx, mux); err != nil {
+		mux.close(err)
+		return nil, false, err
+	}
+
+	c.conn = mux
+	return c.conn, false, nil
+}
+
+// handshake checks the daemon on the other side of a new connection.
+func (c *Client) handshake(ctx context.Context, mux *muxConn) error {
+	resp, err := mux.do(ctx, atomic.AddUint64(&c.lastID, 1), []byte{opHello})
+	if err != nil {
+		return err
+	}
+
+	// The daemons without the hello operation report it as undefined
+	resp, err = parseResponse(resp)
+	if e, ok := err.(*Error); ok {
+		return fmt.Errorf("%w: %v", ErrIncompatible, e)
+	} else if err != nil {
+		return err
+	}
+
+	caps, err := parseCapabilities(resp)
+	if err != nil {
+		return err
+	}
+
+	return c.check(caps)
+}
+
+// muxConn is a daemon connection shared by concurrent requests.
+type muxConn struct {
+	conn net.Conn
+
+	// wmu serializes the request frames
+	wmu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[uint64]chan []byte
+	err     error
+}
+
+func newMuxConn(conn net.Conn) *muxConn {
+	m := &muxConn{
+		conn:    conn,
+		pending: make(map[uint64]chan []byte),
+	}
+
+	go m.read()
+	return m
+}
+
+// do sends the request id and waits for its response.
+func (m *muxConn) do(ctx context.Context, id uint64, request []byte) ([]byte, error) {
+	ch := make(chan []byte, 1)
+
+	m.mu.Lock()
+	if m.err != nil {
+		m.mu.Unlock()
+		return nil, m.err
+	}
+	m.pending[id] = ch
+	m.mu.Unlock()
+
+	frame := make([]byte, 16, 16+len(request))
+	binary.LittleEndian.PutUint64(frame, id)
+	binary.LittleEndian.PutUint64(frame[8:], timeout(ctx))
+	frame = tlv.Encode(append(frame, request...))
+
+	if err := m.write(ctx, frame); err != nil {
+		m.forget(id)
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		return nil, err
+	}
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return nil, m.failure()
+		}
+
+		return resp, nil
+	case <-ctx.Done():
+		m.forget(id)
+		return nil, ctx.Err()
+	}
+}
+
+func (m *muxConn) write(ctx context.Context, frame []byte) error {
+	m.wmu.Lock()
+	defer m.wmu.Unlock()
+
+	deadline, _ := ctx.Deadline()
+	if err := m.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+
+	// Unblock the write if the context is cancelled
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		select {
+		case <-ctx.Done():
+			m.conn.SetWriteDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+
+	defer func() {
+		close(done)
+		<-stopped
+	}()
+
+	_, err := m.conn.Write(frame)
+	if err != nil {
+		// A partial frame leaves the stream out of sync
+		m.close(err)
+	}
+
+	return err
+}
+
+// read delivers the response frames to the pending requests, until the
+// connection fails.
+func (m *muxConn) read() {
+	r := tlv.NewReader(m.conn)
+
+	for {
+		frame, err := r.Next()
+		if err == io.EOF {
+			err = ErrNoResponse
+		}
+
+		if err == nil && len(frame) < 8 {
+			err = fmt.Errorf("blindbidproof: response frame of %d bytes without a request ID", len(frame))
+		}
+
+		if err != nil {
+			m.close(err)
+			return
+		}
+
+		id := binary.LittleEndian.Uint64(frame)
+
+		// The response of an abandoned request is dropped
+		m.mu.Lock()
+		if ch, ok := m.pending[id]; ok {
+			ch <- frame[8:]
+			delete(m.pending, id)
+		}
+		m.mu.Unlock()
+	}
+}
+
+func (m *muxConn) forget(id uint64) {
+	m.mu.Lock()
+	delete(m.pending, id)
+	m.mu.Unlock()
+}
+
+func (m *muxConn) alive() bool {
+	return m.failure() == nil
+}
+
+func (m *muxConn) failure() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.err
+}
+
+// close closes the connection, failing the pending requests with err.
+func (m *muxConn) close(err error) error {
+	m.mu.Lock()
+	if m.err != nil {
+		m.mu.Unlock()
+		return nil
+	}
+
+	m.err = err
+	pending := m.pending
+	m.pending = nil
+	m.mu.Unlock()
+
+	for _, ch := range pending {
+		close(ch)
+	}
+
+	return m.conn.Close()
+}
+
+// timeout returns the time left before the deadline of ctx in milliseconds, or
+// 0 if ctx has no deadline. The daemon abandons the request once it is over.
+func timeout(ctx context.Context) uint64 {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0
+	}
+
+	ms := time.Until(deadline) / time.Millisecond
+	if ms < 1 {
+		ms = 1
+	}
+
+	return uint64(ms)
+}
+
+// idempotent reports whether sending request twice has the same effect as
+// sending it once. A prove job submitted twice would be queued twice.
+func idempotent(request []byte) bool {
+	if len(request) == 0 {
+		return false
+	}
+
+	switch request[0] {
+	case opProve, opVerify, opVerifyBatch, opJobStatus, opHello, opPing:
+		return true
+	default:
+		return false
+	}
+}
+
+func isClosedByPeer(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	_, ok := err.(*net.OpError)
+	return ok
+}
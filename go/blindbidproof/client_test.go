@@ -0,0 +1,334 @@
+package blindbidproof
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gitlab.dusk.network/dusk-core/blindbidproof/go/mimc"
+	"gitlab.dusk.network/dusk-core/blindbidproof/go/tlv"
+)
+
+// listen binds a unix socket in a temporary directory, returning the listener
+// and a function removing it.
+func listen(t *testing.T) (net.Listener, func()) {
+	dir, err := ioutil.TempDir("", "blindbidproof")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("unix", filepath.Join(dir, "daemon"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return l, func() {
+		l.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// fakeCapabilities returns the hello result of a daemon compatible with the
+// client.
+func fakeCapabilities() []byte {
+	var buf bytes.Buffer
+
+	w := tlv.NewWriter(&buf)
+	w.WriteUint64(ProtocolVersion)
+	w.Write([]byte("0.1.0"))
+	w.Write([]byte{1, 2, 3, 4, 5, 6, 7, 8})
+	w.WriteUint64(18312)
+	w.WriteUint64(mimc.Rounds)
+	w.Write(bytes.Repeat([]byte{0xab}, 32))
+
+	return buf.Bytes()
+}
+
+// answerHello answers req if it is a hello request, reporting whether it was.
+func answerHello(conn net.Conn, req []byte) (bool, error) {
+	if len(req) != 17 || req[16] != opHello {
+		return false, nil
+	}
+
+	resp := append(append(req[:8:8], StatusOK), fakeCapabilities()...)
+	_, err := conn.Write(tlv.Encode(resp))
+	return true, err
+}
+
+// fakeDaemon answers every verify request with a valid result, closing each
+// connection after perConn requests besides the handshake. It returns the socket path, a counter of
+// the accepted connections and a function stopping the daemon.
+func fakeDaemon(t *testing.T, perConn int) (string, *int32, func()) {
+	l, stop := listen(t)
+
+	var accepted int32
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&accepted, 1)
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				r := tlv.NewReader(conn)
+				for served := 0; served < perConn; {
+					req, err := r.Next()
+					if err != nil {
+						return
+					}
+
+					if hello, err := answerHello(conn, req); err != nil {
+						return
+					} else if hello {
+						continue
+					}
+					served++
+
+					resp := append(req[:8:8], StatusOK, 0x01)
+					if _, err := conn.Write(tlv.Encode(resp)); err != nil {
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	return l.Addr().String(), &accepted, stop
+}
+
+func TestClientReusesConnections(t *testing.T) {
+	path, accepted, stop := fakeDaemon(t, 10)
+	defer stop()
+
+	c := NewClient(path)
+	defer c.Close()
+
+	for i := 0; i < 5; i++ {
+		ok, err := c.Verify(context.Background(), verifyRequest(&Proof{Proof: bytes.Repeat([]byte{0x01}, 32)}))
+		if err != nil || !ok {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+
+	if n := atomic.LoadInt32(accepted); n != 1 {
+		t.Fatalf("%d connections for sequential requests", n)
+	}
+}
+
+func TestClientRedialsClosedConnections(t *testing.T) {
+	path, accepted, stop := fakeDaemon(t, 1)
+	defer stop()
+
+	c := NewClient(path)
+	defer c.Close()
+
+	for i := 0; i < 3; i++ {
+		ok, err := c.Verify(context.Background(), verifyRequest(&Proof{}))
+		if err != nil || !ok {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+
+	if n := atomic.LoadInt32(accepted); n != 3 {
+		t.Fatalf("%d connections for 3 requests on single use connections", n)
+	}
+}
+
+func TestClientRetriesIdempotentRequestsOnly(t *testing.T) {
+	l, stop := listen(t)
+	defer stop()
+
+	// Answer the handshake and the pings, and drop the connection on any
+	// other request, counting them by operation
+	var mu sync.Mutex
+	received := make(map[byte]int)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				r := tlv.NewReader(conn)
+				for {
+					req, err := r.Next()
+					if err != nil || len(req) < 17 {
+						return
+					}
+
+					if hello, err := answerHello(conn, req); err != nil {
+						return
+					} else if hello {
+						continue
+					}
+
+					if req[16] != opPing {
+						mu.Lock()
+						received[req[16]]++
+						mu.Unlock()
+						return
+					}
+
+					if _, err := conn.Write(tlv.Encode(append(req[:8:8], StatusOK))); err != nil {
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	c := NewClient(l.Addr().String())
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, op := range []byte{opSubmitProve, opVerify} {
+		// Open the connection the request is sent on
+		if err := c.Ping(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		var err error
+		if op == opSubmitProve {
+			_, err = c.SubmitProve(ctx, proveRequest())
+		} else {
+			_, err = c.Verify(ctx, verifyRequest(&Proof{}))
+		}
+
+		if err == nil {
+			t.Fatalf("operation %d: a dropped request succeeded", op)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if received[opSubmitProve] != 1 || received[opVerify] != 2 {
+		t.Fatalf("unexpected requests received: %v", received)
+	}
+}
+
+func TestClientMatchesOutOfOrderResponses(t *testing.T) {
+	l, stop := listen(t)
+	defer stop()
+
+	// Answer every pair of requests in reverse order, echoing the requests
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := tlv.NewReader(conn)
+		if req, err := r.Next(); err != nil {
+			return
+		} else if _, err := answerHello(conn, req); err != nil {
+			return
+		}
+
+		for {
+			first, err := r.Next()
+			if err != nil {
+				return
+			}
+
+			second, err := r.Next()
+			if err != nil {
+				return
+			}
+
+			for _, req := range [][]byte{second, first} {
+				// The timeout left of the context of the requests
+				if ms := binary.LittleEndian.Uint64(req[8:16]); ms == 0 || ms > 10000 {
+					t.Errorf("unexpected request timeout of %dms", ms)
+				}
+
+				resp := append(append(req[:8:8], StatusOK), req[16:]...)
+				if _, err := conn.Write(tlv.Encode(resp)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	c := NewClient(l.Addr().String())
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i byte) {
+			defer wg.Done()
+
+			resp, err := c.roundTrip(ctx, []byte{i})
+			if err != nil {
+				t.Errorf("request %d: %v", i, err)
+				return
+			}
+
+			if !bytes.Equal(resp, []byte{i}) {
+				t.Errorf("request %d answered with %x", i, resp)
+			}
+		}(byte(i))
+	}
+
+	wg.Wait()
+}
+
+func TestClientRefusesIncompatibleDaemons(t *testing.T) {
+	caps, err := parseCapabilities(fakeCapabilities())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !caps.Supports(opHello) || caps.Supports(0) || caps.ParamSetID[0] != 0xab {
+		t.Fatalf("unexpected capabilities %+v", caps)
+	}
+
+	c := new(Client)
+	if err := c.check(caps); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, change := range []func(*Capabilities){
+		func(caps *Capabilities) { caps.ProtocolVersion++ },
+		func(caps *Capabilities) { caps.MiMCRounds = 45 },
+		func(caps *Capabilities) { caps.Opcodes = caps.Opcodes[:3] },
+	} {
+		incompatible := *caps
+		change(&incompatible)
+
+		if err := c.check(&incompatible); !errors.Is(err, ErrIncompatible) {
+			t.Fatalf("unexpected error for %+v: %v", incompatible, err)
+		}
+	}
+
+	c.ParamSetID[0] = 0xcd
+	if err := c.check(caps); !errors.Is(err, ErrIncompatible) {
+		t.Fatalf("a different parameter set was accepted: %v", err)
+	}
+
+	if _, err := parseCapabilities(append(fakeCapabilities(), 0x01, 0x00)); err == nil {
+		t.Fatalf("trailing data after the capabilities was accepted")
+	}
+}
@@ -0,0 +1,132 @@
+package blindbidproof
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"gitlab.dusk.network/dusk-core/blindbidproof/go/mimc"
+	"gitlab.dusk.network/dusk-core/blindbidproof/go/tlv"
+)
+
+// ProtocolVersion is the version of the daemon protocol spoken by this
+// client, as reported by `PROTOCOL_VERSION`.
+const ProtocolVersion = 4
+
+// ErrIncompatible is wrapped by the errors of a Client refusing a daemon.
+var ErrIncompatible = errors.New("blindbidproof: incompatible daemon")
+
+// Capabilities describes a daemon, as returned by its hello operation.
+type Capabilities struct {
+	ProtocolVersion uint64
+	// Version is the crate version of the daemon.
+	Version    string
+	Opcodes    []byte
+	MaxListLen uint64
+	MiMCRounds uint64
+	// ParamSetID identifies the parameters the proofs depend on: daemons with
+	// the same ID create and accept the same proofs.
+	ParamSetID [32]byte
+}
+
+// Supports reports whether the daemon answers the operation code op.
+func (caps *Capabilities) Supports(op byte) bool {
+	return bytes.IndexByte(caps.Opcodes, op) >= 0
+}
+
+// Hello returns the capabilities of the daemon, through DefaultClient.
+func Hello(ctx context.Context) (*Capabilities, error) {
+	return DefaultClient.Hello(ctx)
+}
+
+// Hello returns the capabilities of the daemon.
+func (c *Client) Hello(ctx context.Context) (*Capabilities, error) {
+	resp, err := c.roundTrip(ctx, []byte{opHello})
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCapabilities(resp)
+}
+
+// Ping checks the daemon is ready, through DefaultClient.
+func Ping(ctx context.Context) error {
+	return DefaultClient.Ping(ctx)
+}
+
+// Ping checks the daemon is ready. While the daemon is still loading its
+// parameters, the error is an *Error with StatusNotReady.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.roundTrip(ctx, []byte{opPing})
+	return err
+}
+
+// check returns an error wrapping ErrIncompatible if the client cannot talk
+// to the daemon described by caps.
+func (c *Client) check(caps *Capabilities) error {
+	if caps.ProtocolVersion != ProtocolVersion {
+		return fmt.Errorf("%w: protocol version %d, expected %d", ErrIncompatible, caps.ProtocolVersion, ProtocolVersion)
+	}
+
+	if caps.MiMCRounds != mimc.Rounds {
+		return fmt.Errorf("%w: %d MiMC rounds, expected %d", ErrIncompatible, caps.MiMCRounds, mimc.Rounds)
+	}
+
+	for _, op := range []byte{opProve, opVerify, opVerifyBatch, opSubmitProve, opJobStatus, opCancelJob, opHello, opPing} {
+		if !caps.Supports(op) {
+			return fmt.Errorf("%w: operation %d not supported", ErrIncompatible, op)
+		}
+	}
+
+	if c.ParamSetID != ([32]byte{}) && caps.ParamSetID != c.ParamSetID {
+		return fmt.Errorf("%w: parameter set %x, expected %x", ErrIncompatible, caps.ParamSetID, c.ParamSetID)
+	}
+
+	return nil
+}
+
+func parseCapabilities(resp []byte) (*Capabilities, error) {
+	r := tlv.NewReader(bytes.NewReader(resp))
+	caps := new(Capabilities)
+
+	var err error
+	if caps.ProtocolVersion, err = r.ReadUint64(); err != nil {
+		return nil, fmt.Errorf("blindbidproof: reading the protocol version: %v", err)
+	}
+
+	version, err := r.Next()
+	if err != nil {
+		return nil, fmt.Errorf("blindbidproof: reading the daemon version: %v", err)
+	}
+	caps.Version = string(version)
+
+	if caps.Opcodes, err = r.Next(); err != nil {
+		return nil, fmt.Errorf("blindbidproof: reading the operation codes: %v", err)
+	}
+
+	if caps.MaxListLen, err = r.ReadUint64(); err != nil {
+		return nil, fmt.Errorf("blindbidproof: reading the maximum list length: %v", err)
+	}
+
+	if caps.MiMCRounds, err = r.ReadUint64(); err != nil {
+		return nil, fmt.Errorf("blindbidproof: reading the MiMC rounds: %v", err)
+	}
+
+	id, err := r.Next()
+	if err != nil {
+		return nil, fmt.Errorf("blindbidproof: reading the parameter set ID: %v", err)
+	}
+
+	if len(id) != len(caps.ParamSetID) {
+		return nil, fmt.Errorf("blindbidproof: parameter set ID of %d bytes", len(id))
+	}
+	copy(caps.ParamSetID[:], id)
+
+	if _, err := r.Next(); err != io.EOF {
+		return nil, fmt.Errorf("blindbidproof: trailing data after the capabilities")
+	}
+
+	return caps, nil
+}
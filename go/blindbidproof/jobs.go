@@ -0,0 +1,135 @@
+package blindbidproof
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+)
+
+// JobID identifies a prove job submitted to the daemon.
+type JobID uint64
+
+// JobState is the state of a prove job.
+type JobState byte
+
+// States of a prove job.
+const (
+	JobQueued    JobState = 0x00
+	JobRunning   JobState = 0x01
+	JobDone      JobState = 0x02
+	JobFailed    JobState = 0x03
+	JobCancelled JobState = 0x04
+)
+
+// Finished reports whether the job will not change state anymore.
+func (s JobState) Finished() bool {
+	return s >= JobDone
+}
+
+// Job is the status of a prove job.
+type Job struct {
+	State JobState
+	// Proof and Blindings are set once the job is done.
+	Proof     *Proof
+	Blindings *Blindings
+	// Err is set once the job failed.
+	Err *Error
+}
+
+// SubmitProve queues a prove job on the daemon, through DefaultClient.
+func SubmitProve(ctx context.Context, req ProveRequest) (JobID, error) {
+	return DefaultClient.SubmitProve(ctx, req)
+}
+
+// JobStatus returns the status of a prove job, through DefaultClient.
+func JobStatus(ctx context.Context, id JobID) (*Job, error) {
+	return DefaultClient.JobStatus(ctx, id)
+}
+
+// CancelJob cancels a prove job, through DefaultClient.
+func CancelJob(ctx context.Context, id JobID) (JobState, error) {
+	return DefaultClient.CancelJob(ctx, id)
+}
+
+// SubmitProve queues a prove job on the daemon, returning right away. The
+// proof is collected later with JobStatus, until the daemon discards it after
+// its job TTL. The deadline of ctx, if any, is the deadline of the job.
+func (c *Client) SubmitProve(ctx context.Context, req ProveRequest) (JobID, error) {
+	resp, err := c.roundTrip(ctx, append([]byte{opSubmitProve}, req.encode()...))
+	if err != nil {
+		return 0, err
+	}
+
+	if len(resp) != 8 {
+		return 0, fmt.Errorf("blindbidproof: unexpected job ID of %d bytes", len(resp))
+	}
+
+	return JobID(binary.LittleEndian.Uint64(resp)), nil
+}
+
+// JobStatus returns the status of a prove job. A job that does not exist or
+// expired is reported as an *Error with StatusUnknownJob.
+func (c *Client) JobStatus(ctx context.Context, id JobID) (*Job, error) {
+	resp, err := c.roundTrip(ctx, jobRequest(opJobStatus, id))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseJob(resp)
+}
+
+// CancelJob cancels a prove job, returning its state afterwards. A running
+// job is abandoned at the next check of the daemon; a finished job keeps its
+// result and state.
+func (c *Client) CancelJob(ctx context.Context, id JobID) (JobState, error) {
+	resp, err := c.roundTrip(ctx, jobRequest(opCancelJob, id))
+	if err != nil {
+		return 0, err
+	}
+
+	if len(resp) != 1 {
+		return 0, fmt.Errorf("blindbidproof: unexpected cancel response of %d bytes", len(resp))
+	}
+
+	return JobState(resp[0]), nil
+}
+
+func jobRequest(op byte, id JobID) []byte {
+	req := make([]byte, 9)
+	req[0] = op
+	binary.LittleEndian.PutUint64(req[1:], uint64(id))
+
+	return req
+}
+
+func parseJob(resp []byte) (*Job, error) {
+	if len(resp) == 0 {
+		return nil, fmt.Errorf("blindbidproof: empty job status")
+	}
+
+	job := &Job{State: JobState(resp[0])}
+
+	switch job.State {
+	case JobQueued, JobRunning, JobCancelled:
+		if len(resp) != 1 {
+			return nil, fmt.Errorf("blindbidproof: unexpected job status of %d bytes", len(resp))
+		}
+	case JobDone:
+		proof, blindings, err := parseProve(resp[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		job.Proof, job.Blindings = proof, blindings
+	case JobFailed:
+		if len(resp) < 2 {
+			return nil, fmt.Errorf("blindbidproof: job failure without a status")
+		}
+
+		job.Err = &Error{Status: resp[1], Message: string(resp[2:])}
+	default:
+		return nil, fmt.Errorf("blindbidproof: unknown job state 0x%02x", resp[0])
+	}
+
+	return job, nil
+}
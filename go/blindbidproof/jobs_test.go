@@ -0,0 +1,78 @@
+package blindbidproof
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"gitlab.dusk.network/dusk-core/blindbidproof/go/tlv"
+)
+
+func TestParseJob(t *testing.T) {
+	job, err := parseJob([]byte{byte(JobRunning)})
+	if err != nil || job.State != JobRunning || job.State.Finished() {
+		t.Fatalf("unexpected job %+v: %v", job, err)
+	}
+
+	proof := &Proof{Proof: bytes.Repeat([]byte{0xaa}, 32), Commitments: [][]byte{fixtureD[:]}}
+	b, err := proof.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := append([]byte{byte(JobDone)}, tlv.Encode(b)...)
+	for _, s := range []Scalar{fixtureD, fixtureK, fixtureSeed} {
+		done = append(done, tlv.Encode(s[:])...)
+	}
+
+	job, err = parseJob(done)
+	if err != nil || job.State != JobDone || !bytes.Equal(job.Proof.Proof, proof.Proof) || job.Blindings.K != fixtureK {
+		t.Fatalf("unexpected job %+v: %v", job, err)
+	}
+
+	job, err = parseJob(append([]byte{byte(JobFailed), StatusListTooLarge}, "too large"...))
+	if err != nil || !job.State.Finished() || job.Err.Status != StatusListTooLarge || job.Err.Message != "too large" {
+		t.Fatalf("unexpected job %+v: %v", job, err)
+	}
+
+	for _, resp := range [][]byte{nil, {byte(JobQueued), 0x00}, {byte(JobFailed)}, {0x05}} {
+		if _, err := parseJob(resp); err == nil {
+			t.Fatalf("the job status %x was accepted", resp)
+		}
+	}
+}
+
+func TestProveJob(t *testing.T) {
+	requireDaemon(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
+	defer cancel()
+
+	id, err := SubmitProve(ctx, proveRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var job *Job
+	for job == nil || !job.State.Finished() {
+		time.Sleep(100 * time.Millisecond)
+
+		if job, err = JobStatus(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if job.State != JobDone {
+		t.Fatalf("the job finished as 0x%02x: %v", job.State, job.Err)
+	}
+
+	ok, err := Verify(ctx, verifyRequest(job.Proof))
+	if err != nil || !ok {
+		t.Fatalf("the proof of the job was rejected: %v", err)
+	}
+
+	if state, err := CancelJob(ctx, id); err != nil || state != JobDone {
+		t.Fatalf("cancelling a finished job: 0x%02x, %v", state, err)
+	}
+}
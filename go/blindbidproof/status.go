@@ -0,0 +1,86 @@
+package blindbidproof
+
+import (
+	"context"
+	"fmt"
+)
+
+// Status codes of the daemon responses, as returned by `Error::code`.
+const (
+	StatusOK                  byte = 0x00
+	StatusIo                  byte = 0x01
+	StatusTlv                 byte = 0x02
+	StatusR1CS                byte = 0x03
+	StatusOther               byte = 0x04
+	StatusUnexpectedEOF       byte = 0x05
+	StatusListTooLarge        byte = 0x06
+	StatusTreeTooDeep         byte = 0x07
+	StatusBusy                byte = 0x08
+	StatusUnknownJob          byte = 0x09
+	StatusDeadlineExceeded    byte = 0x0a
+	StatusCancelled           byte = 0x0b
+	StatusNotReady            byte = 0x0c
+	StatusShuttingDown        byte = 0x0d
+	StatusCommitmentCount     byte = 0x0e
+	StatusEmptyList           byte = 0x0f
+	StatusListLenMismatch     byte = 0x10
+	StatusToggleOutOfRange    byte = 0x11
+	StatusNonCanonicalScalar  byte = 0x12
+	StatusUnknownProofVersion byte = 0x13
+	StatusParamSetMismatch    byte = 0x14
+	StatusUnknownOperation    byte = 0x15
+)
+
+// Error is a failure reported by the daemon.
+type Error struct {
+	Status  byte
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("blindbidproof: daemon error 0x%02x: %s", e.Status, e.Message)
+}
+
+// BadRequest reports whether the daemon rejected the request as malformed or
+// out of its limits, as opposed to failing on a valid request.
+func (e *Error) BadRequest() bool {
+	switch e.Status {
+	case StatusIo, StatusTlv, StatusUnexpectedEOF, StatusListTooLarge, StatusTreeTooDeep,
+		StatusCommitmentCount, StatusEmptyList, StatusListLenMismatch, StatusToggleOutOfRange,
+		StatusNonCanonicalScalar, StatusUnknownProofVersion, StatusParamSetMismatch,
+		StatusUnknownOperation:
+		return true
+	default:
+		return false
+	}
+}
+
+// Temporary reports whether the daemon rejected the request because it was
+// busy, not ready yet or shutting down, so the same request may succeed later.
+func (e *Error) Temporary() bool {
+	switch e.Status {
+	case StatusBusy, StatusNotReady, StatusShuttingDown:
+		return true
+	default:
+		return false
+	}
+}
+
+// Is makes a StatusDeadlineExceeded error match context.DeadlineExceeded.
+func (e *Error) Is(target error) bool {
+	return e.Status == StatusDeadlineExceeded && target == context.DeadlineExceeded
+}
+
+// parseResponse splits a response envelope into its result, or returns the
+// error it reports.
+func parseResponse(resp []byte) ([]byte, error) {
+	if len(resp) == 0 {
+		return nil, fmt.Errorf("blindbidproof: empty response")
+	}
+
+	if resp[0] != StatusOK {
+		return nil, &Error{Status: resp[0], Message: string(resp[1:])}
+	}
+
+	return resp[1:], nil
+}
@@ -0,0 +1,54 @@
+package blindbidproof
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestParseResponse(t *testing.T) {
+	result, err := parseResponse([]byte{StatusOK, 0x01})
+	if err != nil || !bytes.Equal(result, []byte{0x01}) {
+		t.Fatalf("unexpected result %x: %v", result, err)
+	}
+
+	_, err = parseResponse(append([]byte{StatusTlv}, "Invalid length"...))
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if e.Status != StatusTlv || e.Message != "Invalid length" || !e.BadRequest() {
+		t.Fatalf("unexpected error %+v", e)
+	}
+
+	_, err = parseResponse([]byte{StatusToggleOutOfRange})
+	if e, ok := err.(*Error); !ok || !e.BadRequest() || e.Temporary() {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	_, err = parseResponse([]byte{StatusUnknownOperation})
+	if e, ok := err.(*Error); !ok || !e.BadRequest() || e.Temporary() {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	_, err = parseResponse([]byte{StatusOther})
+	if e, ok := err.(*Error); !ok || e.BadRequest() {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	_, err = parseResponse([]byte{StatusBusy})
+	if e, ok := err.(*Error); !ok || e.BadRequest() || !e.Temporary() {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	_, err = parseResponse([]byte{StatusDeadlineExceeded})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if _, err := parseResponse(nil); err == nil {
+		t.Fatalf("an empty response was accepted")
+	}
+}
@@ -0,0 +1,3 @@
+module gitlab.dusk.network/dusk-core/blindbidproof/go
+
+go 1.13
@@ -0,0 +1,112 @@
+// Package mimc reproduces the blind bid hashing of the dusk-blindbidproof
+// crate, so bids can be computed without a round trip to the daemon.
+//
+// The hash is MiMC over the ristretto scalar field, with 90 rounds of the x^7
+// permutation. The round constants are a SHA-512 chain seeded with
+// "blind bid", every digest reduced modulo the group order l.
+package mimc
+
+import (
+	"crypto/sha512"
+	"math/big"
+)
+
+// Rounds is the number of MiMC rounds.
+const Rounds = 90
+
+// l is the order of the ristretto group, 2^252 + 27742317777372353535851937790883648493.
+var l, _ = new(big.Int).SetString("7237005577332262213973186563042994240857116359379907606001950938285454250989", 10)
+
+var constants = deriveConstants()
+
+// Scalar is the canonical little-endian encoding of a ristretto scalar.
+type Scalar [32]byte
+
+// NewScalar returns the scalar of an u64.
+func NewScalar(v uint64) Scalar {
+	return fromInt(new(big.Int).SetUint64(v))
+}
+
+// Constants returns the MiMC round constants.
+func Constants() []Scalar {
+	c := make([]Scalar, Rounds)
+	for i := range constants {
+		c[i] = fromInt(constants[i])
+	}
+
+	return c
+}
+
+// Hash computes the MiMC image of left and right, as `mimc_hash` does.
+func Hash(left, right Scalar) Scalar {
+	return fromInt(hash(toInt(left), toInt(right)))
+}
+
+func hash(left, key *big.Int) *big.Int {
+	x := new(big.Int).Set(left)
+	a, a2, a4 := new(big.Int), new(big.Int), new(big.Int)
+
+	for _, c := range constants {
+		// a = x + k + c[i]
+		a.Add(x, key)
+		a.Add(a, c)
+		a.Mod(a, l)
+
+		// x = a^7 = a^4 * a^2 * a
+		a2.Mul(a, a)
+		a2.Mod(a2, l)
+		a4.Mul(a2, a2)
+		a4.Mod(a4, l)
+		x.Mul(a4, a2)
+		x.Mod(x, l)
+		x.Mul(x, a)
+		x.Mod(x, l)
+	}
+
+	x.Add(x, key)
+	return x.Mod(x, l)
+}
+
+func deriveConstants() []*big.Int {
+	c := make([]*big.Int, 0, Rounds)
+
+	h := sha512.Sum512([]byte("blind bid"))
+	for i := 0; i < Rounds; i++ {
+		// Scalar::from_bytes_mod_order_wide
+		wide := new(big.Int).SetBytes(reverse(h[:]))
+		wide.Mod(wide, l)
+		c = append(c, wide)
+
+		s := fromInt(wide)
+		h = sha512.Sum512(s[:])
+	}
+
+	return c
+}
+
+// toInt reduces a scalar modulo l.
+func toInt(s Scalar) *big.Int {
+	i := new(big.Int).SetBytes(reverse(s[:]))
+	return i.Mod(i, l)
+}
+
+// fromInt encodes an integer in [0, l).
+func fromInt(i *big.Int) Scalar {
+	var s Scalar
+
+	b := i.Bytes()
+	for j := range b {
+		s[j] = b[len(b)-1-j]
+	}
+
+	return s
+}
+
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i := range b {
+		r[i] = b[len(b)-1-i]
+	}
+
+	return r
+}
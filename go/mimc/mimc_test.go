@@ -0,0 +1,98 @@
+package mimc
+
+import (
+	"bufio"
+	"encoding/hex"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// loadVectors reads the known-answer vectors shared with tests/mimc_vectors.rs.
+func loadVectors(t *testing.T) [][]string {
+	f, err := os.Open("testdata/vectors.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var vectors [][]string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		vectors = append(vectors, strings.Fields(scanner.Text()))
+	}
+
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return vectors
+}
+
+func scalar(t *testing.T, s string) Scalar {
+	var sc Scalar
+
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(sc) {
+		t.Fatalf("malformed scalar %q", s)
+	}
+
+	copy(sc[:], b)
+	return sc
+}
+
+func TestVectors(t *testing.T) {
+	constants := Constants()
+
+	for _, v := range loadVectors(t) {
+		switch v[0] {
+		case "constant":
+			i, err := strconv.Atoi(v[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if constants[i] != scalar(t, v[2]) {
+				t.Errorf("constant %d: got %x", i, constants[i])
+			}
+
+		case "hash":
+			h := Hash(scalar(t, v[1]), scalar(t, v[2]))
+			if h != scalar(t, v[3]) {
+				t.Errorf("hash(%s, %s): got %x", v[1], v[2], h)
+			}
+
+		case "derive":
+			d, k, seed := scalar(t, v[1]), scalar(t, v[2]), scalar(t, v[3])
+			expected := Witness{
+				M:    scalar(t, v[4]),
+				X:    scalar(t, v[5]),
+				Y:    scalar(t, v[6]),
+				YInv: scalar(t, v[7]),
+				Z:    scalar(t, v[8]),
+				Q:    scalar(t, v[9]),
+			}
+
+			if w := Derive(d, k, seed); w != expected {
+				t.Errorf("derive(%s, %s, %s): got %x", v[1], v[2], v[3], w)
+			}
+
+			if X(d, k) != expected.X || Z(k, seed) != expected.Z || Score(d, k, seed) != expected.Q {
+				t.Errorf("derive(%s, %s, %s): X, Z and Score differ from the witness", v[1], v[2], v[3])
+			}
+
+		default:
+			t.Fatalf("unknown vector %q", v[0])
+		}
+	}
+}
+
+func BenchmarkDerive(b *testing.B) {
+	d, k, seed := NewScalar(20000), NewScalar(7), NewScalar(9)
+
+	for i := 0; i < b.N; i++ {
+		Derive(d, k, seed)
+	}
+}
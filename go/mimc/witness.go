@@ -0,0 +1,62 @@
+package mimc
+
+import "math/big"
+
+// Witness holds the values of a bid that the blind bid circuit recomputes.
+//
+// X is the value the bid is registered with in the public list, Z the image
+// published for the round and Q the score of the bid.
+type Witness struct {
+	M    Scalar
+	X    Scalar
+	Y    Scalar
+	YInv Scalar
+	Z    Scalar
+	Q    Scalar
+}
+
+// Derive computes the witness of a bid with value d and secret k for the
+// round seed, as `BidWitness::derive` does.
+func Derive(d, k, seed Scalar) Witness {
+	di, si := toInt(d), toInt(seed)
+
+	m := hash(toInt(k), new(big.Int))
+	x := hash(di, m)
+	y := hash(si, x)
+	z := hash(si, m)
+
+	// Scalar::invert maps zero to zero
+	yInv := new(big.Int)
+	if y.Sign() != 0 {
+		yInv.ModInverse(y, l)
+	}
+
+	q := new(big.Int).Mul(di, yInv)
+	q.Mod(q, l)
+
+	return Witness{
+		M:    fromInt(m),
+		X:    fromInt(x),
+		Y:    fromInt(y),
+		YInv: fromInt(yInv),
+		Z:    fromInt(z),
+		Q:    fromInt(q),
+	}
+}
+
+// X computes the value H(d, H(k, 0)) a bid is registered with.
+func X(d, k Scalar) Scalar {
+	m := hash(toInt(k), new(big.Int))
+	return fromInt(hash(toInt(d), m))
+}
+
+// Z computes the image H(seed, H(k, 0)) of a bid for the round seed.
+func Z(k, seed Scalar) Scalar {
+	m := hash(toInt(k), new(big.Int))
+	return fromInt(hash(toInt(seed), m))
+}
+
+// Score computes the score of a bid with value d for the round seed.
+func Score(d, k, seed Scalar) Scalar {
+	return Derive(d, k, seed).Q
+}
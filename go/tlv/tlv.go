@@ -0,0 +1,219 @@
+// Package tlv implements the dusk-tlv framing used by the blind bid daemon.
+//
+// A frame is a type byte, a little-endian length and the value. The type byte
+// holds the size in bytes of the length: 1, 2, 4 or 8, whichever is the
+// smallest that fits the value.
+//
+// A list, as written by `TlvWriter::write_list`, is a single frame whose value
+// is the concatenation of one frame per item. Lists nest by using the
+// concatenated frames of the inner list as an item of the outer one.
+//
+// Serde encoded values are framed as well: an u64 is the frame of its 8
+// little-endian bytes, and a scalar is the frame of its 32 canonical bytes.
+package tlv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// MaxFrameLen is the longest value a Reader accepts, far above any frame of
+// the daemon.
+const MaxFrameLen = 1 << 30
+
+// ErrLengthType is returned when a frame declares an unsupported length size.
+var ErrLengthType = errors.New("tlv: invalid length type")
+
+// ErrFrameTooLarge is returned when a frame declares a value longer than
+// MaxFrameLen.
+var ErrFrameTooLarge = errors.New("tlv: frame too large")
+
+// Writer writes dusk-tlv frames to an underlying writer.
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter returns a Writer on top of w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write writes value as a single frame.
+func (w *Writer) Write(value []byte) error {
+	var header [9]byte
+
+	n := uint64(len(value))
+	switch {
+	case n <= 0xff:
+		header[0], header[1] = 1, byte(n)
+	case n <= 0xffff:
+		header[0] = 2
+		binary.LittleEndian.PutUint16(header[1:], uint16(n))
+	case n <= 0xffffffff:
+		header[0] = 4
+		binary.LittleEndian.PutUint32(header[1:], uint32(n))
+	default:
+		header[0] = 8
+		binary.LittleEndian.PutUint64(header[1:], n)
+	}
+
+	if _, err := w.w.Write(header[:1+header[0]]); err != nil {
+		return err
+	}
+
+	_, err := w.w.Write(value)
+	return err
+}
+
+// WriteList writes items as a list frame.
+func (w *Writer) WriteList(items [][]byte) error {
+	return w.Write(List(items))
+}
+
+// WriteUint64 writes the serde encoding of an u64.
+func (w *Writer) WriteUint64(v uint64) error {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	return w.Write(b[:])
+}
+
+// WriteScalar writes the serde encoding of a scalar.
+func (w *Writer) WriteScalar(s [32]byte) error {
+	return w.Write(s[:])
+}
+
+// Reader reads dusk-tlv frames from an underlying reader.
+type Reader struct {
+	r io.Reader
+}
+
+// NewReader returns a Reader on top of r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// Next reads the value of the next frame. It returns io.EOF only when no
+// byte of a new frame could be read.
+func (r *Reader) Next() ([]byte, error) {
+	var header [9]byte
+	if _, err := io.ReadFull(r.r, header[:1]); err != nil {
+		return nil, err
+	}
+
+	switch header[0] {
+	case 1, 2, 4, 8:
+	default:
+		return nil, ErrLengthType
+	}
+
+	if _, err := io.ReadFull(r.r, header[1:1+header[0]]); err != nil {
+		return nil, unexpected(err)
+	}
+	n := binary.LittleEndian.Uint64(header[1:])
+	if n > MaxFrameLen {
+		return nil, ErrFrameTooLarge
+	}
+
+	// Grow with the data actually read instead of trusting the length
+	var value bytes.Buffer
+	if _, err := io.CopyN(&value, r.r, int64(n)); err != nil {
+		return nil, unexpected(err)
+	}
+
+	return value.Bytes(), nil
+}
+
+// ReadList reads a list frame and splits it into its items.
+func (r *Reader) ReadList() ([][]byte, error) {
+	value, err := r.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	return Split(value)
+}
+
+// ReadUint64 reads the serde encoding of an u64.
+func (r *Reader) ReadUint64() (uint64, error) {
+	value, err := r.Next()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(value) != 8 {
+		return 0, errors.New("tlv: an u64 must be encoded in 8 bytes")
+	}
+
+	return binary.LittleEndian.Uint64(value), nil
+}
+
+// ReadScalar reads the serde encoding of a scalar.
+func (r *Reader) ReadScalar() ([32]byte, error) {
+	var s [32]byte
+
+	value, err := r.Next()
+	if err != nil {
+		return s, err
+	}
+
+	if len(value) != 32 {
+		return s, errors.New("tlv: a scalar must be encoded in 32 bytes")
+	}
+
+	copy(s[:], value)
+	return s, nil
+}
+
+// Encode returns value as a single frame.
+func Encode(value []byte) []byte {
+	var buf bytes.Buffer
+	NewWriter(&buf).Write(value)
+	return buf.Bytes()
+}
+
+// EncodeList returns items as a list frame.
+func EncodeList(items [][]byte) []byte {
+	return Encode(List(items))
+}
+
+// List returns the value of a list frame holding items, that is the
+// concatenation of their frames. It is also the item to use for a nested list.
+func List(items [][]byte) []byte {
+	var buf bytes.Buffer
+
+	w := NewWriter(&buf)
+	for _, item := range items {
+		w.Write(item)
+	}
+
+	return buf.Bytes()
+}
+
+// Split splits the value of a list frame into its items.
+func Split(value []byte) ([][]byte, error) {
+	items := [][]byte{}
+
+	r := NewReader(bytes.NewReader(value))
+	for {
+		item, err := r.Next()
+		if err == io.EOF {
+			return items, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
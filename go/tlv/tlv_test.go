@@ -0,0 +1,158 @@
+package tlv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// loadFixtures reads the frames written by the Rust side in tests/tlv_fixtures.rs.
+func loadFixtures(t *testing.T) map[string][]byte {
+	f, err := os.Open("testdata/fixtures.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fixtures := make(map[string][]byte)
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			t.Fatalf("malformed fixture line %q", scanner.Text())
+		}
+
+		b, err := hex.DecodeString(fields[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fixtures[fields[0]] = b
+	}
+
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return fixtures
+}
+
+func scalar(v byte) [32]byte {
+	var s [32]byte
+	s[0] = v
+	return s
+}
+
+func TestFixtures(t *testing.T) {
+	fixtures := loadFixtures(t)
+
+	seven, eleven := scalar(7), scalar(11)
+
+	encoders := map[string]func(w *Writer) error{
+		"empty":      func(w *Writer) error { return w.Write(nil) },
+		"bytes":      func(w *Writer) error { return w.Write([]byte("dusk")) },
+		"u16_length": func(w *Writer) error { return w.Write(bytes.Repeat([]byte{0xab}, 300)) },
+		"u32_length": func(w *Writer) error { return w.Write(bytes.Repeat([]byte{0x01}, 65536)) },
+		"list": func(w *Writer) error {
+			return w.WriteList([][]byte{[]byte("a"), []byte("bc"), {}})
+		},
+		"empty_list": func(w *Writer) error { return w.WriteList(nil) },
+		"nested_list": func(w *Writer) error {
+			inner := List([][]byte{[]byte("x"), []byte("yz")})
+			return w.WriteList([][]byte{inner, []byte("w")})
+		},
+		"scalar":      func(w *Writer) error { return w.WriteScalar(seven) },
+		"u64":         func(w *Writer) error { return w.WriteUint64(5) },
+		"scalar_list": func(w *Writer) error { return w.WriteList([][]byte{seven[:], eleven[:]}) },
+	}
+
+	if len(encoders) != len(fixtures) {
+		t.Fatalf("%d fixtures for %d encoders", len(fixtures), len(encoders))
+	}
+
+	for name, encode := range encoders {
+		var buf bytes.Buffer
+		if err := encode(NewWriter(&buf)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if !bytes.Equal(buf.Bytes(), fixtures[name]) {
+			t.Errorf("%s: encoding differs from the Rust fixture", name)
+		}
+	}
+}
+
+func TestDecodeFixtures(t *testing.T) {
+	fixtures := loadFixtures(t)
+
+	value, err := NewReader(bytes.NewReader(fixtures["u16_length"])).Next()
+	if err != nil || !bytes.Equal(value, bytes.Repeat([]byte{0xab}, 300)) {
+		t.Fatalf("u16_length: %v", err)
+	}
+
+	value, err = NewReader(bytes.NewReader(fixtures["u32_length"])).Next()
+	if err != nil || len(value) != 65536 {
+		t.Fatalf("u32_length: %v", err)
+	}
+
+	items, err := NewReader(bytes.NewReader(fixtures["nested_list"])).ReadList()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inner, err := Split(items[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]byte{[]byte("x"), []byte("yz")}
+	if !reflect.DeepEqual(inner, expected) || !bytes.Equal(items[1], []byte("w")) {
+		t.Fatalf("nested_list decoded as %q", items)
+	}
+
+	items, err = NewReader(bytes.NewReader(fixtures["empty_list"])).ReadList()
+	if err != nil || len(items) != 0 {
+		t.Fatalf("empty_list decoded as %q: %v", items, err)
+	}
+
+	s, err := NewReader(bytes.NewReader(fixtures["scalar"])).ReadScalar()
+	if err != nil || s != scalar(7) {
+		t.Fatalf("scalar decoded as %x: %v", s, err)
+	}
+
+	v, err := NewReader(bytes.NewReader(fixtures["u64"])).ReadUint64()
+	if err != nil || v != 5 {
+		t.Fatalf("u64 decoded as %d: %v", v, err)
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	cases := map[string]struct {
+		frame []byte
+		err   error
+	}{
+		"length type":  {[]byte{0x03, 0x00}, ErrLengthType},
+		"short length": {[]byte{0x02, 0x01}, io.ErrUnexpectedEOF},
+		"short value":  {[]byte{0x01, 0x04, 'd', 'u'}, io.ErrUnexpectedEOF},
+		"empty":        {nil, io.EOF},
+		"huge length":  {[]byte{0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x80, 'd'}, ErrFrameTooLarge},
+		"above max":    {[]byte{0x04, 0x01, 0x00, 0x00, 0x40, 'd'}, ErrFrameTooLarge},
+	}
+
+	for name, c := range cases {
+		if _, err := NewReader(bytes.NewReader(c.frame)).Next(); err != c.err {
+			t.Errorf("%s: expected %v, got %v", name, c.err, err)
+		}
+	}
+
+	if _, err := Split([]byte{0x01, 0x02, 'a'}); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated list item: got %v", err)
+	}
+}
@@ -1,7 +1,19 @@
 #!/bin/bash
-./target/debug/dusk-blindbidproof &
+READY_FILE=$(mktemp -u)
+./target/debug/dusk-blindbidproof --ready-file "$READY_FILE" &
 SOCKET_PID=$!
-go test ./blindbidproof
+
+# Wait for the daemon to answer before running the tests
+while [ ! -e "$READY_FILE" ]; do
+  if ! kill -0 $SOCKET_PID 2>/dev/null; then
+    echo "The daemon exited before being ready" >&2
+    exit 1
+  fi
+  sleep 0.1
+done
+rm -f "$READY_FILE"
+
+(cd go && go test ./blindbidproof)
 BID_STATUS=$?
 kill -15 $SOCKET_PID
 exit $BID_STATUS
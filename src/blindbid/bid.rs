@@ -1,6 +1,7 @@
+use super::scalar_from_bytes;
 use crate::Error;
 
-use std::cmp;
+use std::convert::TryFrom;
 use std::io::Read;
 
 use curve25519_dalek::scalar::Scalar;
@@ -13,18 +14,43 @@ pub struct Bid {
 
 impl Bid {
     pub fn try_list_from_reader<R: Read>(reader: R) -> Result<Vec<Bid>, Error> {
-        Ok(TlvReader::new(reader).read_list()?)
+        TlvReader::new(reader)
+            .read_list::<Vec<u8>>()?
+            .into_iter()
+            .map(Bid::try_from)
+            .collect()
     }
 }
 
-impl From<Vec<u8>> for Bid {
-    fn from(bytes: Vec<u8>) -> Self {
-        let mut s = [0x00u8; 32];
+impl TryFrom<Vec<u8>> for Bid {
+    type Error = Error;
 
-        s.copy_from_slice(&bytes.as_slice()[..cmp::max(bytes.len(), 32)]);
+    fn try_from(bytes: Vec<u8>) -> Result<Self, Self::Error> {
+        Ok(Bid {
+            x: scalar_from_bytes(bytes.as_slice())?,
+        })
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
 
-        Bid {
-            x: Scalar::from_bits(s),
+    use dusk_tlv::TlvWriter;
+
+    #[test]
+    fn bid_needs_32_bytes() {
+        assert!(Bid::try_from(vec![0x01; 32]).is_ok());
+        assert!(Bid::try_from(vec![0x01; 31]).is_err());
+        assert!(Bid::try_from(vec![0x01; 33]).is_err());
+        assert!(Bid::try_from(vec![]).is_err());
+        match Bid::try_from(vec![0xff; 32]) {
+            Err(Error::NonCanonicalScalar) => (),
+            r => panic!("Unexpected result of a non-canonical bid: {:?}", r),
         }
+
+        let mut list = TlvWriter::new(vec![]);
+        list.write_list(&[vec![0x01; 32], vec![0x01; 8]]).unwrap();
+        assert!(Bid::try_list_from_reader(list.into_inner().as_slice()).is_err());
     }
 }
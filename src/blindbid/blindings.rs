@@ -0,0 +1,98 @@
+use super::scalar_from_bytes;
+use crate::Error;
+
+use bulletproofs::PedersenGens;
+use curve25519_dalek::ristretto::CompressedRistretto;
+use curve25519_dalek::scalar::Scalar;
+use rand::thread_rng;
+
+/// Blinding factors of the commitments of a proof, one for each of d, k and y^-1.
+///
+/// The blinding of d is the one of the commitment the bid was locked with, so the verifier can
+/// check the proof is about that bid, see `Verify::d_commitment`.
+#[derive(Debug, Clone, Copy, PartialEq)]
+pub struct Blindings {
+    pub d: Scalar,
+    pub k: Scalar,
+    pub y_inv: Scalar,
+}
+
+impl Blindings {
+    pub fn new(d: Scalar, k: Scalar, y_inv: Scalar) -> Self {
+        Blindings { d, k, y_inv }
+    }
+
+    pub fn random() -> Self {
+        let mut rng = thread_rng();
+
+        Blindings::new(
+            Scalar::random(&mut rng),
+            Scalar::random(&mut rng),
+            Scalar::random(&mut rng),
+        )
+    }
+
+    /// Decode the blindings from the frames of d, k and y^-1, as in a prove request.
+    pub fn try_from_frames(frames: &[Vec<u8>]) -> Result<Self, Error> {
+        match frames {
+            [d, k, y_inv] => Ok(Blindings::new(
+                scalar_from_bytes(d.as_slice())?,
+                scalar_from_bytes(k.as_slice())?,
+                scalar_from_bytes(y_inv.as_slice())?,
+            )),
+            _ => Err(Error::io_invalid_data(
+                "The blindings of d, k and y^-1 must be provided together",
+            )),
+        }
+    }
+
+    /// Encode the blindings as the frames of d, k and y^-1, as in a prove response.
+    pub fn to_frames(&self) -> Vec<Vec<u8>> {
+        [self.d, self.k, self.y_inv]
+            .iter()
+            .map(|s| s.as_bytes().to_vec())
+            .collect()
+    }
+
+    /// Pedersen commitment to `d` with this blinding, the first commitment of the proof.
+    pub fn d_commitment(&self, d: Scalar) -> CompressedRistretto {
+        PedersenGens::default().commit(d, self.d).compress()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn d_commitment_is_pedersen() {
+        let gens = PedersenGens::default();
+        let blindings = Blindings::random();
+        let d = Scalar::from(20000u64);
+
+        assert_eq!(
+            (d * gens.B + blindings.d * gens.B_blinding).compress(),
+            blindings.d_commitment(d)
+        );
+        assert_ne!(blindings, Blindings::random());
+    }
+
+    #[test]
+    fn frames_round_trip() {
+        let blindings = Blindings::random();
+        let frames = blindings.to_frames();
+
+        assert_eq!(
+            blindings,
+            Blindings::try_from_frames(frames.as_slice()).unwrap()
+        );
+        assert!(Blindings::try_from_frames(&frames[..2]).is_err());
+
+        let mut non_canonical = frames.clone();
+        non_canonical[1] = vec![0xff; 32];
+        match Blindings::try_from_frames(non_canonical.as_slice()) {
+            Err(Error::NonCanonicalScalar) => (),
+            r => panic!("Unexpected result {:?}", r),
+        }
+    }
+}
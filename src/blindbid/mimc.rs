@@ -0,0 +1,28 @@
+use super::CONSTANTS;
+use crate::gadgets::MIMC_ROUNDS;
+
+use curve25519_dalek::scalar::Scalar;
+
+/// The round constants shared by [`mimc_hash`] and the MiMC gadget.
+pub fn mimc_constants() -> &'static [Scalar] {
+    &CONSTANTS
+}
+
+/// Off-circuit MiMC hash, computing the same image as `mimc_gadget`.
+///
+/// Every round maps `x` to `(x + right + c[i])^7`, starting with `x = left`, and the
+/// result is the last `x` plus `right`.
+pub fn mimc_hash(left: &Scalar, right: &Scalar) -> Scalar {
+    let mut x = *left;
+    let key = *right;
+
+    for c in CONSTANTS.iter().take(MIMC_ROUNDS) {
+        let a = x + key + c;
+        let a_2 = a * a;
+        let a_4 = a_2 * a_2;
+
+        x = a_4 * a_2 * a;
+    }
+
+    x + key
+}
@@ -1,4 +1,8 @@
-use bulletproofs::{BulletproofGens, PedersenGens};
+use crate::gadgets::MIMC_ROUNDS;
+use crate::Error;
+
+use std::sync::Arc;
+
 use curve25519_dalek::scalar::Scalar;
 use merlin::Transcript;
 use sha2::Digest;
@@ -6,13 +10,13 @@ use sha2::Sha512;
 
 lazy_static! {
     static ref CONSTANTS: Vec<Scalar> = {
-        let mut constants: Vec<Scalar> = Vec::with_capacity(90);
+        let mut constants: Vec<Scalar> = Vec::with_capacity(MIMC_ROUNDS);
 
         let h = Sha512::digest(b"blind bid");
         let mut hash: [u8; 64] = [0; 64];
         hash.copy_from_slice(h.as_slice());
 
-        for _ in 0..90 {
+        for _ in 0..MIMC_ROUNDS {
             let c = Scalar::from_bytes_mod_order_wide(&hash);
             constants.push(c);
             let h = Sha512::digest(&c.to_bytes());
@@ -23,18 +27,331 @@ lazy_static! {
     };
 }
 
+/// Multipliers of the four MiMC chains and of the score gadget
+const CIRCUIT_MULTIPLIERS: usize = 4 * 4 * MIMC_ROUNDS + 2;
+/// Multipliers added by every entry of the bid list, besides its toggle
+const BID_MULTIPLIERS: usize = 3;
+
+/// Multipliers added by every level of the bid tree: the position bit, half of one for the
+/// sibling, the swap and a MiMC chain
+const LEVEL_MULTIPLIERS: usize = 3 + 4 * MIMC_ROUNDS;
+
+/// Label of the transcripts of the proofs
+const TRANSCRIPT_LABEL: &[u8] = b"BlindBidProofGadget";
+/// Label of the digests of the bid lists
+const LIST_DIGEST_LABEL: &[u8] = b"BlindBidList";
+/// Label of the digests of the bid trees
+const TREE_DIGEST_LABEL: &[u8] = b"BlindBidTree";
+
+/// Upper bound of the bulletproofs generators that will be created
+pub const MAX_GENERATORS: usize = 1 << 16;
+/// Longest bid list that fits in `MAX_GENERATORS`, two toggles taking one multiplier
+pub const MAX_LIST_LEN: usize =
+    (MAX_GENERATORS - CIRCUIT_MULTIPLIERS) * 2 / (2 * BID_MULTIPLIERS + 1);
+/// Deepest bid tree accepted by the prover and the verifier
+pub const MAX_TREE_DEPTH: usize = 32;
+
 pub use bid::Bid;
-pub use proof::Proof;
-pub use verify::Verify;
+pub use blindings::Blindings;
+pub use mimc::{mimc_constants, mimc_hash};
+pub use params::{param_set_id, params, params_loaded, preload, Params};
+pub use proof::{Proof, ProofVersion};
+pub use scalar::{read_scalar, read_scalar_list, scalar_from_bytes};
+pub use tree::{BidTree, MerklePath};
+pub use verify::{Verify, VerifyTree};
+pub use witness::BidWitness;
 
 mod bid;
+mod blindings;
+mod mimc;
+mod params;
 mod proof;
+mod scalar;
+mod tree;
 mod verify;
+mod witness;
+
+/// Number of generators required to prove a bid list of `list_len` entries.
+///
+/// The prover pads the multipliers to the next power of two. The toggles allocated in the
+/// circuit by `ProofVersion::V2` fill half a multiplier each, and the capacity is enough for the
+/// `ProofVersion::V1` proofs too.
+pub fn generators_capacity(list_len: usize) -> Result<usize, Error> {
+    if list_len > MAX_LIST_LEN {
+        return Err(Error::ListTooLarge(list_len));
+    }
+
+    let toggles = (list_len + 1) / 2;
+    Ok((CIRCUIT_MULTIPLIERS + BID_MULTIPLIERS * list_len + toggles).next_power_of_two())
+}
+
+/// Fail unless a bid list of `len` entries can be proven.
+fn check_list_len(len: usize) -> Result<(), Error> {
+    if len == 0 {
+        return Err(Error::EmptyList);
+    }
+
+    if len > MAX_LIST_LEN {
+        return Err(Error::ListTooLarge(len));
+    }
+
+    Ok(())
+}
+
+fn check_commitments(len: usize, version: ProofVersion) -> Result<(), Error> {
+    if len != version.commitments() {
+        return Err(Error::CommitmentCount(len, version.commitments()));
+    }
+
+    Ok(())
+}
+
+/// Digest of the bid list a proof is made against, recorded in its envelope.
+pub fn list_digest(pub_list: &[Scalar]) -> [u8; 32] {
+    let mut hasher = Sha512::new();
+    hasher.input(LIST_DIGEST_LABEL);
+    hasher.input(&(pub_list.len() as u64).to_le_bytes());
+    for x in pub_list {
+        hasher.input(x.as_bytes());
+    }
+
+    let mut digest = [0x00u8; 32];
+    digest.copy_from_slice(&hasher.result()[..32]);
+    digest
+}
+
+/// Same as `list_digest`, for the bid tree of a `Proof::prove_tree` proof.
+pub fn tree_digest(root: &Scalar, depth: usize) -> [u8; 32] {
+    let mut hasher = Sha512::new();
+    hasher.input(TREE_DIGEST_LABEL);
+    hasher.input(root.as_bytes());
+    hasher.input(&(depth as u64).to_le_bytes());
+
+    let mut digest = [0x00u8; 32];
+    digest.copy_from_slice(&hasher.result()[..32]);
+    digest
+}
+
+/// Number of generators required to prove the membership in a bid tree of `depth` levels.
+pub fn tree_generators_capacity(depth: usize) -> Result<usize, Error> {
+    if depth > MAX_TREE_DEPTH {
+        return Err(Error::TreeTooDeep(depth));
+    }
+
+    Ok((CIRCUIT_MULTIPLIERS + LEVEL_MULTIPLIERS * depth).next_power_of_two())
+}
+
+pub fn generate_cs_transcript(capacity: usize) -> (Arc<Params>, Transcript) {
+    let params = params(capacity);
+    let transcript = Transcript::new(TRANSCRIPT_LABEL);
+
+    (params, transcript)
+}
+
+/// Append the public inputs of a bid list proof to its transcript, ahead of the commitments.
+///
+/// `context` is opaque to the proof, e.g. the chain ID, round and step it is made for: the
+/// proof only verifies with the same inputs and context. `message`, e.g. a block hash, makes
+/// the proof a signature of the bidder over it.
+///
+/// `ProofVersion::V1` proofs keep the transcript they were made with before the inputs were
+/// bound, so the ones made then still verify: they are bound to none of the inputs.
+fn bind_list_inputs(
+    transcript: &mut Transcript,
+    version: ProofVersion,
+    context: &[u8],
+    message: Option<&[u8]>,
+    score: &Scalar,
+    z_img: &Scalar,
+    seed: &Scalar,
+    pub_list: &[Scalar],
+) {
+    if version == ProofVersion::V1 {
+        return;
+    }
+
+    bind_inputs(transcript, context, message, score, z_img, seed);
+
+    transcript.append_u64(b"list_len", pub_list.len() as u64);
+    for x in pub_list {
+        transcript.append_message(b"bid", x.as_bytes());
+    }
+}
+
+/// Same as `bind_list_inputs`, for a bid tree proof.
+fn bind_tree_inputs(
+    transcript: &mut Transcript,
+    context: &[u8],
+    message: Option<&[u8]>,
+    score: &Scalar,
+    z_img: &Scalar,
+    seed: &Scalar,
+    root: &Scalar,
+    depth: usize,
+) {
+    bind_inputs(transcript, context, message, score, z_img, seed);
+
+    transcript.append_message(b"root", root.as_bytes());
+    transcript.append_u64(b"depth", depth as u64);
+}
+
+fn bind_inputs(
+    transcript: &mut Transcript,
+    context: &[u8],
+    message: Option<&[u8]>,
+    score: &Scalar,
+    z_img: &Scalar,
+    seed: &Scalar,
+) {
+    transcript.append_message(b"context", context);
+
+    // A proof without a message differs from one over an empty message
+    transcript.append_u64(b"has_message", message.is_some() as u64);
+    transcript.append_message(b"message", message.unwrap_or_default());
+    transcript.append_message(b"score", score.as_bytes());
+    transcript.append_message(b"z_img", z_img.as_bytes());
+    transcript.append_message(b"seed", seed.as_bytes());
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn capacity_follows_list_len() {
+        assert_eq!(2048, generators_capacity(1).unwrap());
+        assert_eq!(4096, generators_capacity(300).unwrap());
+        assert_eq!(18312, MAX_LIST_LEN);
+        assert_eq!(MAX_GENERATORS, generators_capacity(MAX_LIST_LEN).unwrap());
+
+        // The second toggle of a multiplier fits in it
+        assert_eq!(2048, generators_capacity(172).unwrap());
+        assert_eq!(2048, generators_capacity(173).unwrap());
+        assert_eq!(4096, generators_capacity(174).unwrap());
+
+        match generators_capacity(MAX_LIST_LEN + 1) {
+            Err(Error::ListTooLarge(n)) => assert_eq!(MAX_LIST_LEN + 1, n),
+            r => panic!("Unexpected result {:?}", r),
+        }
+    }
+
+    #[test]
+    fn list_len_and_commitments() {
+        assert!(check_list_len(1).is_ok());
+        assert!(check_list_len(MAX_LIST_LEN).is_ok());
+
+        match check_list_len(0) {
+            Err(Error::EmptyList) => (),
+            r => panic!("Unexpected result {:?}", r),
+        }
+        match check_list_len(MAX_LIST_LEN + 1) {
+            Err(Error::ListTooLarge(n)) => assert_eq!(MAX_LIST_LEN + 1, n),
+            r => panic!("Unexpected result {:?}", r),
+        }
+
+        assert!(check_commitments(3, ProofVersion::V2).is_ok());
+        assert!(check_commitments(4, ProofVersion::V1).is_ok());
+        match check_commitments(4, ProofVersion::V2) {
+            Err(Error::CommitmentCount(4, 3)) => (),
+            r => panic!("Unexpected result {:?}", r),
+        }
+        match check_commitments(3, ProofVersion::V1) {
+            Err(Error::CommitmentCount(3, 4)) => (),
+            r => panic!("Unexpected result {:?}", r),
+        }
+    }
+
+    #[test]
+    fn inputs_change_the_transcript() {
+        let one = Scalar::one();
+        let challenge = |context: &[u8], message: Option<&[u8]>, pub_list: &[Scalar]| {
+            let mut transcript = Transcript::new(TRANSCRIPT_LABEL);
+            bind_list_inputs(
+                &mut transcript,
+                ProofVersion::V2,
+                context,
+                message,
+                &one,
+                &one,
+                &one,
+                pub_list,
+            );
+
+            let mut challenge = [0x00u8; 32];
+            transcript.challenge_bytes(b"test", &mut challenge);
+            challenge
+        };
+
+        let list = [one, Scalar::from(2u64)];
+        let block = Some(&b"block hash"[..]);
+
+        assert_eq!(
+            challenge(b"round 1", block, &list),
+            challenge(b"round 1", block, &list)
+        );
+        assert_ne!(
+            challenge(b"round 1", block, &list),
+            challenge(b"round 2", block, &list)
+        );
+        assert_ne!(
+            challenge(b"round 1", block, &list),
+            challenge(b"round 1", block, &list[..1])
+        );
+        assert_ne!(
+            challenge(b"round 1", block, &list),
+            challenge(b"round 1", Some(&b"other block"[..]), &list)
+        );
+        assert_ne!(
+            challenge(b"round 1", None, &list),
+            challenge(b"round 1", Some(&[][..]), &list)
+        );
+    }
+
+    #[test]
+    fn v1_transcript_binds_no_input() {
+        let one = Scalar::one();
+        let challenge = |bind: bool| {
+            let mut transcript = Transcript::new(TRANSCRIPT_LABEL);
+            if bind {
+                bind_list_inputs(
+                    &mut transcript,
+                    ProofVersion::V1,
+                    b"round 1",
+                    Some(&b"block hash"[..]),
+                    &one,
+                    &one,
+                    &one,
+                    &[one],
+                );
+            }
+
+            let mut challenge = [0x00u8; 32];
+            transcript.challenge_bytes(b"test", &mut challenge);
+            challenge
+        };
+
+        // The transcript of the v1 proofs made before the inputs were bound
+        assert_eq!(challenge(false), challenge(true));
+    }
+
+    #[test]
+    fn digests_follow_the_bids() {
+        let list = [Scalar::one(), Scalar::from(2u64)];
+        let swapped = [Scalar::from(2u64), Scalar::one()];
+
+        assert_eq!(list_digest(&list), list_digest(&list));
+        assert_ne!(list_digest(&list), list_digest(&swapped));
+        assert_ne!(list_digest(&list), list_digest(&list[..1]));
 
-pub fn generate_cs_transcript() -> (PedersenGens, BulletproofGens, Transcript) {
-    let pc_gens = PedersenGens::default();
-    let bp_gens = BulletproofGens::new(2048, 1);
-    let transcript = Transcript::new(b"BlindBidProofGadget");
+        assert_ne!(tree_digest(&list[0], 16), tree_digest(&list[0], 17));
+        assert_ne!(tree_digest(&list[0], 16), tree_digest(&list[1], 16));
+        assert_ne!(list_digest(&[]), tree_digest(&Scalar::zero(), 0));
+    }
 
-    (pc_gens, bp_gens, transcript)
+    #[test]
+    fn capacity_follows_tree_depth() {
+        assert_eq!(8192, tree_generators_capacity(16).unwrap());
+        assert_eq!(16384, tree_generators_capacity(MAX_TREE_DEPTH).unwrap());
+        assert!(tree_generators_capacity(MAX_TREE_DEPTH + 1).is_err());
+    }
 }
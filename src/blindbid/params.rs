@@ -0,0 +1,97 @@
+use super::{CONSTANTS, TRANSCRIPT_LABEL};
+use crate::gadgets::MIMC_ROUNDS;
+
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::sync::{Arc, RwLock};
+
+use bulletproofs::{BulletproofGens, PedersenGens};
+use sha2::{Digest, Sha512};
+
+static PRELOADED: AtomicBool = AtomicBool::new(false);
+
+lazy_static! {
+    static ref PARAMS: RwLock<Arc<Params>> = RwLock::new(Arc::new(Params::new(2048)));
+    static ref PARAM_SET_ID: [u8; 32] = {
+        let pc_gens = PedersenGens::default();
+
+        let mut hasher = Sha512::new();
+        hasher.input(TRANSCRIPT_LABEL);
+        hasher.input(&(MIMC_ROUNDS as u64).to_le_bytes());
+        for c in CONSTANTS.iter() {
+            hasher.input(c.as_bytes());
+        }
+        hasher.input(pc_gens.B.compress().as_bytes());
+        hasher.input(pc_gens.B_blinding.compress().as_bytes());
+
+        let mut id = [0x00u8; 32];
+        id.copy_from_slice(&hasher.result()[..32]);
+        id
+    };
+}
+
+/// Generators shared read-only by every prover and verifier.
+#[derive(Clone)]
+pub struct Params {
+    pub pc_gens: PedersenGens,
+    pub bp_gens: BulletproofGens,
+}
+
+impl Params {
+    pub fn new(capacity: usize) -> Self {
+        Params {
+            pc_gens: PedersenGens::default(),
+            bp_gens: BulletproofGens::new(capacity, 1),
+        }
+    }
+
+    /// Number of multipliers the generators can prove
+    pub fn capacity(&self) -> usize {
+        self.bp_gens.gens_capacity
+    }
+}
+
+/// Return the process-wide parameters, growing them if they are below `capacity`.
+///
+/// The parameters are created on first use; call it at startup with the expected capacity to
+/// keep their creation out of the requests.
+pub fn params(capacity: usize) -> Arc<Params> {
+    {
+        let params = PARAMS.read().expect("Parameters lock poisoned");
+        if params.capacity() >= capacity {
+            return Arc::clone(&params);
+        }
+    }
+
+    let mut params = PARAMS.write().expect("Parameters lock poisoned");
+    if params.capacity() < capacity {
+        debug!("Growing the bulletproofs generators to {}", capacity);
+
+        // The existing generators are kept, only the missing ones are created
+        let mut grown = Params::clone(&params);
+        grown.bp_gens.increase_capacity(capacity);
+
+        *params = Arc::new(grown);
+    }
+
+    Arc::clone(&params)
+}
+
+/// Create the parameters for `capacity` ahead of the requests, see `params_loaded`.
+pub fn preload(capacity: usize) {
+    params(capacity);
+    PRELOADED.store(true, Ordering::SeqCst);
+}
+
+/// Whether `preload` is done, so the requests up to its capacity find the generators ready.
+pub fn params_loaded() -> bool {
+    PRELOADED.load(Ordering::SeqCst)
+}
+
+/// Identifier of the parameters the proofs depend on: the transcript label, the MiMC constants
+/// and the Pedersen generators.
+///
+/// Two daemons with the same identifier and protocol version create and accept the same proofs. The bulletproofs
+/// generators are left out, since they only depend on the library and on the capacity.
+pub fn param_set_id() -> [u8; 32] {
+    *PARAM_SET_ID
+}
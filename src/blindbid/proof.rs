@@ -1,23 +1,69 @@
-use super::{generate_cs_transcript, Bid, CONSTANTS};
-use crate::gadgets::proof_gadget;
-use crate::Error;
+use super::{
+    bind_list_inputs, bind_tree_inputs, check_list_len, generate_cs_transcript,
+    generators_capacity, list_digest, param_set_id, read_scalar, tree_digest,
+    tree_generators_capacity, Bid, Blindings, MerklePath, CONSTANTS,
+};
+use crate::gadgets::{proof_gadget, tree_proof_gadget};
+use crate::{Cancel, Error};
 
 use std::convert::{TryFrom, TryInto};
 use std::io::{Read, Write};
 
-use bulletproofs::r1cs::Prover;
-use bulletproofs::r1cs::{LinearCombination, R1CSProof};
+use bulletproofs::r1cs::{ConstraintSystem, Prover};
+use bulletproofs::r1cs::{LinearCombination, R1CSProof, Variable};
 use curve25519_dalek::ristretto::CompressedRistretto;
 use curve25519_dalek::scalar::Scalar;
 use dusk_tlv::{TlvReader, TlvWriter};
 use rand::thread_rng;
-use serde::Deserialize;
+use serde::{Deserialize, Serialize};
+
+/// Leading bytes of the header of an encoded proof, followed by its version
+const PROOF_MAGIC: &[u8; 4] = b"dbbp";
+
+/// Layout of a proof.
+///
+/// `V1` commits to y as well, although the circuit never uses it, and commits every toggle of
+/// the bid list in `t_c`. `V2` drops the commitment to y and allocates the toggles inside the
+/// circuit, so its size only grows with the logarithm of the bid list size. The proofs are made
+/// with `V2`. `V1` is still decoded and verified during the transition, with the transcript the
+/// `V1` proofs were made with: it binds neither the public inputs, the context nor the message.
+///
+/// A `V2` proof is encoded in a self-describing envelope, see `TryInto<Vec<u8>> for Proof`.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+pub enum ProofVersion {
+    V1 = 1,
+    V2 = 2,
+}
+
+impl ProofVersion {
+    /// Number of commitments: d, k and y^-1, plus y for `V1`.
+    ///
+    /// y^-1 is the last commitment of both versions.
+    pub fn commitments(self) -> usize {
+        match self {
+            ProofVersion::V1 => 4,
+            ProofVersion::V2 => 3,
+        }
+    }
+
+    /// Number of `t_c` entries of a proof over a bid list of `list_len` entries.
+    pub fn t_c_len(self, list_len: usize) -> usize {
+        match self {
+            ProofVersion::V1 => list_len,
+            ProofVersion::V2 => 0,
+        }
+    }
+}
 
 #[derive(Debug, Clone)]
 pub struct Proof {
     pub proof: R1CSProof,
     pub commitments: Vec<CompressedRistretto>,
     pub t_c: Vec<CompressedRistretto>,
+    pub version: ProofVersion,
+    /// Digest of the bid list, or of the bid tree, the proof was made against. `V1` proofs do
+    /// not carry it.
+    pub list_digest: Option<[u8; 32]>,
 }
 
 impl Proof {
@@ -25,85 +71,324 @@ impl Proof {
         proof: R1CSProof,
         commitments: Vec<CompressedRistretto>,
         t_c: Vec<CompressedRistretto>,
+        version: ProofVersion,
+        list_digest: Option<[u8; 32]>,
     ) -> Self {
         Proof {
             proof,
             commitments,
             t_c,
+            version,
+            list_digest,
         }
     }
 
+    /// Prove the bid `pub_list[toggle]` was made with `d` and `k`, and that its score is `q`.
+    ///
+    /// `q`, `z_img`, `seed`, the bid list and `context` are appended to the transcript, so the
+    /// proof only verifies against the same public inputs, in the same context.
+    ///
+    /// The `message` is appended too, if any, making the proof a signature of the anonymous
+    /// bidder over it, e.g. over the block or the vote the proof comes with.
+    ///
+    /// The commitments are made with `blindings`, or with random ones if `None`, and the
+    /// blindings used are returned along with the proof. The blinding of d is expected to be
+    /// the one of the commitment the bid was locked with, see `Verify::d_commitment`.
+    ///
+    /// The proof has the `ProofVersion::V2` layout.
     pub fn prove(
         d: Scalar,
         k: Scalar,
-        y: Scalar,
         y_inv: Scalar,
         q: Scalar,
         z_img: Scalar,
         seed: Scalar,
         pub_list: Vec<Bid>,
         toggle: u64,
-    ) -> Result<Self, Error> {
-        let (pc_gens, bp_gens, mut transcript) = generate_cs_transcript();
+        context: &[u8],
+        message: Option<&[u8]>,
+        blindings: Option<Blindings>,
+    ) -> Result<(Self, Blindings), Error> {
+        Proof::prove_cancellable(
+            d,
+            k,
+            y_inv,
+            q,
+            z_img,
+            seed,
+            pub_list,
+            toggle,
+            context,
+            message,
+            blindings,
+            &Cancel::default(),
+        )
+    }
+
+    /// Same as `prove`, giving up with the error of `cancel` between the phases of the proof.
+    ///
+    /// The bid list and the toggle are checked before the constraint system is built.
+    ///
+    /// The bulletproofs prover itself cannot be interrupted, so `cancel` is last checked right
+    /// before it starts.
+    pub fn prove_cancellable(
+        d: Scalar,
+        k: Scalar,
+        y_inv: Scalar,
+        q: Scalar,
+        z_img: Scalar,
+        seed: Scalar,
+        pub_list: Vec<Bid>,
+        toggle: u64,
+        context: &[u8],
+        message: Option<&[u8]>,
+        blindings: Option<Blindings>,
+        cancel: &Cancel,
+    ) -> Result<(Self, Blindings), Error> {
+        Proof::prove_list(
+            ProofVersion::V2,
+            d,
+            k,
+            y_inv,
+            q,
+            z_img,
+            seed,
+            pub_list,
+            toggle,
+            context,
+            message,
+            blindings,
+            cancel,
+        )
+    }
+
+    /// Same as `prove_cancellable`, with the layout of `version`.
+    ///
+    /// `ProofVersion::V1` commits to y with a random blinding.
+    fn prove_list(
+        version: ProofVersion,
+        d: Scalar,
+        k: Scalar,
+        y_inv: Scalar,
+        q: Scalar,
+        z_img: Scalar,
+        seed: Scalar,
+        pub_list: Vec<Bid>,
+        toggle: u64,
+        context: &[u8],
+        message: Option<&[u8]>,
+        blindings: Option<Blindings>,
+        cancel: &Cancel,
+    ) -> Result<(Self, Blindings), Error> {
+        check_list_len(pub_list.len())?;
+        if toggle >= pub_list.len() as u64 {
+            return Err(Error::ToggleOutOfRange(toggle, pub_list.len()));
+        }
+
+        cancel.check()?;
+        let (params, mut transcript) = generate_cs_transcript(generators_capacity(pub_list.len())?);
+        cancel.check()?;
+
+        let list: Vec<Scalar> = pub_list.iter().map(|bid| bid.x).collect();
+        bind_list_inputs(
+            &mut transcript,
+            version,
+            context,
+            message,
+            &q,
+            &z_img,
+            &seed,
+            &list,
+        );
 
         // 1. Create a prover
-        let mut prover = Prover::new(&pc_gens, &mut transcript);
+        let mut prover = Prover::new(&params.pc_gens, &mut transcript);
 
         // 2. Commit high-level variables
-        let mut blinding_rng = rand::thread_rng();
+        let blindings = blindings.unwrap_or_else(Blindings::random);
+        let (commitments, vars) = commit(&mut prover, version, d, k, y_inv, &blindings);
 
-        let (commitments, vars): (Vec<_>, Vec<_>) = [d, k, y, y_inv]
-            .iter()
-            .map(|v| prover.commit(*v, Scalar::random(&mut blinding_rng)))
-            .unzip();
-
-        let (t_c, t_v): (Vec<_>, Vec<_>) = (0..pub_list.len())
-            .map(|x| {
-                prover.commit(
-                    Scalar::from((x as u64 == toggle) as u8),
-                    Scalar::random(&mut thread_rng()),
-                )
-            })
-            .unzip();
+        let toggles = (0..pub_list.len()).map(|x| Scalar::from((x as u64 == toggle) as u8));
+        let (t_c, t_v): (Vec<_>, Vec<_>) = match version {
+            ProofVersion::V1 => toggles
+                .map(|t| prover.commit(t, Scalar::random(&mut thread_rng())))
+                .unzip(),
+            ProofVersion::V2 => (
+                vec![],
+                toggles
+                    .map(|t| prover.allocate(Some(t)))
+                    .collect::<Result<_, _>>()?,
+            ),
+        };
 
         // public list of numbers
-        let l_v: Vec<LinearCombination> =
-            pub_list.iter().map(|bid| bid.x.into()).collect::<Vec<_>>();
+        let l_v: Vec<LinearCombination> = list.iter().map(|&x| x.into()).collect::<Vec<_>>();
 
         // 3. Build a CS
         proof_gadget(
             &mut prover,
             vars[0].into(),
             vars[1].into(),
-            vars[3].into(),
+            vars[vars.len() - 1].into(),
             q.into(),
             z_img.into(),
             seed.into(),
             &CONSTANTS,
             t_v,
             l_v,
+        )?;
+
+        // 4. Make a proof
+        cancel.check()?;
+        let proof = prover.prove(&params.bp_gens)?;
+
+        let digest = Some(list_digest(&list));
+        Ok((
+            Proof::new(proof, commitments, t_c, version, digest),
+            blindings,
+        ))
+    }
+
+    /// Prove the bid is a leaf of the bid tree with the provided root.
+    ///
+    /// The membership is proven with the authentication path of the bid, so the proof carries
+    /// no `t_c` and its size only grows with the depth of the tree. The other arguments are the
+    /// ones of `prove`.
+    pub fn prove_tree(
+        d: Scalar,
+        k: Scalar,
+        y_inv: Scalar,
+        q: Scalar,
+        z_img: Scalar,
+        seed: Scalar,
+        root: Scalar,
+        path: &MerklePath,
+        context: &[u8],
+        message: Option<&[u8]>,
+        blindings: Option<Blindings>,
+    ) -> Result<(Self, Blindings), Error> {
+        Proof::prove_tree_cancellable(
+            d,
+            k,
+            y_inv,
+            q,
+            z_img,
+            seed,
+            root,
+            path,
+            context,
+            message,
+            blindings,
+            &Cancel::default(),
+        )
+    }
+
+    /// Same as `prove_tree`, giving up with the error of `cancel` between the phases of the
+    /// proof, as `prove_cancellable` does.
+    pub fn prove_tree_cancellable(
+        d: Scalar,
+        k: Scalar,
+        y_inv: Scalar,
+        q: Scalar,
+        z_img: Scalar,
+        seed: Scalar,
+        root: Scalar,
+        path: &MerklePath,
+        context: &[u8],
+        message: Option<&[u8]>,
+        blindings: Option<Blindings>,
+        cancel: &Cancel,
+    ) -> Result<(Self, Blindings), Error> {
+        let depth = path.siblings.len();
+        let capacity = tree_generators_capacity(depth)?;
+
+        cancel.check()?;
+        let (params, mut transcript) = generate_cs_transcript(capacity);
+        cancel.check()?;
+
+        bind_tree_inputs(
+            &mut transcript,
+            context,
+            message,
+            &q,
+            &z_img,
+            &seed,
+            &root,
+            depth,
         );
 
+        // 1. Create a prover
+        let mut prover = Prover::new(&params.pc_gens, &mut transcript);
+
+        // 2. Commit high-level variables
+        let blindings = blindings.unwrap_or_else(Blindings::random);
+        let (commitments, vars) = commit(&mut prover, ProofVersion::V2, d, k, y_inv, &blindings);
+        cancel.check()?;
+
+        // position bit and sibling of every level
+        let levels = path
+            .siblings
+            .iter()
+            .enumerate()
+            .map(|(i, sibling)| Some((Scalar::from((path.index >> i) & 1), *sibling)))
+            .collect();
+
+        // 3. Build a CS
+        tree_proof_gadget(
+            &mut prover,
+            vars[0].into(),
+            vars[1].into(),
+            vars[2].into(),
+            q.into(),
+            z_img.into(),
+            seed.into(),
+            &CONSTANTS,
+            levels,
+            root.into(),
+        )?;
+
         // 4. Make a proof
-        let proof = prover.prove(&bp_gens)?;
+        cancel.check()?;
+        let proof = prover.prove(&params.bp_gens)?;
 
-        Ok(Proof::new(proof, commitments, t_c))
+        Ok((
+            Proof::new(
+                proof,
+                commitments,
+                vec![],
+                ProofVersion::V2,
+                Some(tree_digest(&root, depth)),
+            ),
+            blindings,
+        ))
     }
 
     /// Perform the deserialization of a request.
     ///
     /// Currently the recommended method from TlvReaderis read_list instead of standard list
     /// deserialization
-    pub fn try_from_reader_variables<R: Read>(reader: R) -> Result<Self, Error> {
+    pub fn try_from_reader_variables<R: Read>(reader: R) -> Result<(Self, Blindings), Error> {
+        Proof::try_from_reader_cancellable(reader, &Cancel::default())
+    }
+
+    /// Same as `try_from_reader_variables`, proving with `prove_cancellable`.
+    ///
+    /// The blindings of d, k and y^-1 are the last three frames of the request, if any, and
+    /// random ones are used otherwise.
+    pub fn try_from_reader_cancellable<R: Read>(
+        reader: R,
+        cancel: &Cancel,
+    ) -> Result<(Self, Blindings), Error> {
         let mut reader = TlvReader::new(reader);
 
-        let d = Deserialize::deserialize(&mut reader)?;
-        let k = Deserialize::deserialize(&mut reader)?;
-        let y = Deserialize::deserialize(&mut reader)?;
-        let y_inv = Deserialize::deserialize(&mut reader)?;
-        let q = Deserialize::deserialize(&mut reader)?;
-        let z_img = Deserialize::deserialize(&mut reader)?;
-        let seed = Deserialize::deserialize(&mut reader)?;
+        let d = read_scalar(&mut reader)?;
+        let k = read_scalar(&mut reader)?;
+        // y is still part of the request, but no longer committed to
+        read_scalar(&mut reader)?;
+        let y_inv = read_scalar(&mut reader)?;
+        let q = read_scalar(&mut reader)?;
+        let z_img = read_scalar(&mut reader)?;
+        let seed = read_scalar(&mut reader)?;
 
         let mut reader = reader.into_inner();
         let pub_list = Bid::try_list_from_reader(&mut reader)?;
@@ -111,32 +396,98 @@ impl Proof {
         let mut reader = TlvReader::new(reader);
         let toggle = Deserialize::deserialize(&mut reader)?;
 
-        Proof::prove(d, k, y, y_inv, q, z_img, seed, pub_list, toggle)
+        // The context is optional, an absent one being empty, and so are the message and the
+        // blindings after it
+        let context = reader.next().transpose()?.unwrap_or_default();
+        let mut trailing = vec![];
+        while let Some(frame) = reader.next().transpose()? {
+            trailing.push(frame);
+        }
+
+        let blindings = match trailing.len() {
+            0 | 1 => None,
+            3 | 4 => {
+                let frames = trailing.split_off(trailing.len() - 3);
+                Some(Blindings::try_from_frames(frames.as_slice())?)
+            }
+            n => {
+                return Err(Error::io_invalid_data(format!(
+                    "{} frames follow the context, instead of the message and the blindings",
+                    n
+                )))
+            }
+        };
+        let message = trailing.pop();
+
+        Proof::prove_cancellable(
+            d,
+            k,
+            y_inv,
+            q,
+            z_img,
+            seed,
+            pub_list,
+            toggle,
+            context.as_slice(),
+            message.as_ref().map(Vec::as_slice),
+            blindings,
+            cancel,
+        )
     }
 }
 
+/// Commit d, k and y^-1 with `blindings`, and y as well for `ProofVersion::V1`.
+fn commit(
+    prover: &mut Prover,
+    version: ProofVersion,
+    d: Scalar,
+    k: Scalar,
+    y_inv: Scalar,
+    blindings: &Blindings,
+) -> (Vec<CompressedRistretto>, Vec<Variable>) {
+    let mut values = vec![(d, blindings.d), (k, blindings.k)];
+    if version == ProofVersion::V1 {
+        values.push((y_inv.invert(), Scalar::random(&mut thread_rng())));
+    }
+    values.push((y_inv, blindings.y_inv));
+
+    values
+        .iter()
+        .map(|(v, blinding)| prover.commit(*v, *blinding))
+        .unzip()
+}
+
 impl TryInto<Vec<u8>> for Proof {
     type Error = Error;
 
+    /// A `ProofVersion::V1` proof is the R1CS proof, the commitments and `t_c`.
+    ///
+    /// A `ProofVersion::V2` proof is an envelope: a header with `PROOF_MAGIC` and the version
+    /// byte, the parameter set ID of the proof, the digest of its bid list, the R1CS proof and
+    /// the commitments.
     fn try_into(self) -> Result<Vec<u8>, Self::Error> {
         let buf = vec![];
         let mut buf = TlvWriter::new(buf);
 
+        if self.version != ProofVersion::V1 {
+            let digest = self.list_digest.ok_or(Error::Other(
+                "The digest of the bid list of the proof is missing".to_owned(),
+            ))?;
+
+            let mut header = PROOF_MAGIC.to_vec();
+            header.push(self.version as u8);
+
+            buf.write(header.as_slice())?;
+            buf.write(&param_set_id())?;
+            buf.write(&digest)?;
+        }
+
         buf.write(self.proof.to_bytes().as_slice())?;
-        buf.write_list(
-            self.commitments
-                .iter()
-                .map(|c| c.to_bytes()[..].to_vec())
-                .collect::<Vec<Vec<u8>>>()
-                .as_slice(),
-        )?;
-        buf.write_list(
-            self.t_c
-                .iter()
-                .map(|c| c.to_bytes()[..].to_vec())
-                .collect::<Vec<Vec<u8>>>()
-                .as_slice(),
-        )?;
+        buf.write_list(points(&self.commitments).as_slice())?;
+
+        if self.version == ProofVersion::V1 {
+            buf.write_list(points(&self.t_c).as_slice())?;
+        }
 
         Ok(buf.into_inner())
     }
@@ -145,40 +496,299 @@ impl TryInto<Vec<u8>> for Proof {
 impl TryFrom<Vec<u8>> for Proof {
     type Error = Error;
 
+    /// Decode both layouts: a `ProofVersion::V1` proof starts with the R1CS proof itself, which
+    /// is never as short as the header of an envelope.
+    ///
+    /// An envelope of another version fails with `Error::UnknownProofVersion`, and one made with
+    /// other parameters than `param_set_id` with `Error::ParamSetMismatch`.
     fn try_from(bytes: Vec<u8>) -> Result<Self, Self::Error> {
         let mut reader = TlvReader::new(bytes.as_slice());
 
-        let proof = reader
+        let mut proof = reader
             .next()
             .ok_or(Error::io_unexpected_eof("The proof was not supplied"))??;
-        let proof = R1CSProof::from_bytes(proof.as_slice())?;
 
-        let mut commitments = vec![];
-        for c in reader.read_list::<Vec<u8>>()? {
-            if c.len() != 32 {
+        let mut version = ProofVersion::V1;
+        let mut digest = None;
+
+        if proof.len() == PROOF_MAGIC.len() + 1 && proof.starts_with(PROOF_MAGIC) {
+            version = match proof[PROOF_MAGIC.len()] {
+                v if v == ProofVersion::V2 as u8 => ProofVersion::V2,
+                v => return Err(Error::UnknownProofVersion(v)),
+            };
+
+            let param_set = reader.next().ok_or(Error::io_unexpected_eof(
+                "The parameter set ID was not supplied",
+            ))??;
+            if param_set.as_slice() != &param_set_id()[..] {
+                return Err(Error::ParamSetMismatch);
+            }
+
+            let list = reader.next().ok_or(Error::io_unexpected_eof(
+                "The digest of the bid list was not supplied",
+            ))??;
+            if list.len() != 32 {
                 return Err(Error::io_invalid_data(
-                    "Compressed Ristrettos can only be created from 32 bytes slices",
+                    "The digest of the bid list must be 32 bytes",
                 ));
             }
 
-            // This function panics if the size is different from 32
-            let cr = CompressedRistretto::from_slice(c.as_slice());
-            commitments.push(cr);
+            let mut d = [0x00u8; 32];
+            d.copy_from_slice(list.as_slice());
+            digest = Some(d);
+
+            proof = reader
+                .next()
+                .ok_or(Error::io_unexpected_eof("The proof was not supplied"))??;
         }
 
-        let mut t_c = vec![];
-        for t in reader.read_list::<Vec<u8>>()? {
-            if t.len() != 32 {
-                return Err(Error::io_invalid_data(
-                    "Compressed Ristrettos can only be created from 32 bytes slices",
-                ));
+        let proof = R1CSProof::from_bytes(proof.as_slice())?;
+        let commitments = read_points(&mut reader)?;
+        let t_c = match version {
+            ProofVersion::V1 => read_points(&mut reader)?,
+            ProofVersion::V2 => vec![],
+        };
+
+        Ok(Proof::new(proof, commitments, t_c, version, digest))
+    }
+}
+
+fn points(points: &[CompressedRistretto]) -> Vec<Vec<u8>> {
+    points.iter().map(|p| p.to_bytes()[..].to_vec()).collect()
+}
+
+fn read_points<R: Read>(reader: &mut TlvReader<R>) -> Result<Vec<CompressedRistretto>, Error> {
+    let mut points = vec![];
+    for p in reader.read_list::<Vec<u8>>()? {
+        if p.len() != 32 {
+            return Err(Error::io_invalid_data(
+                "Compressed Ristrettos can only be created from 32 bytes slices",
+            ));
+        }
+
+        // This function panics if the size is different from 32
+        points.push(CompressedRistretto::from_slice(p.as_slice()));
+    }
+
+    Ok(points)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::{BidWitness, Verify};
+
+    #[test]
+    fn invalid_toggle_or_list() {
+        let one = Scalar::one();
+        let prove = |pub_list, toggle| {
+            Proof::prove(
+                one,
+                one,
+                one,
+                one,
+                one,
+                one,
+                pub_list,
+                toggle,
+                &[],
+                None,
+                None,
+            )
+        };
+
+        match prove(vec![Bid { x: one }, Bid { x: one }], 2) {
+            Err(Error::ToggleOutOfRange(2, 2)) => (),
+            r => panic!("Unexpected result {:?}", r),
+        }
+
+        match prove(vec![], 0) {
+            Err(Error::EmptyList) => (),
+            r => panic!("Unexpected result {:?}", r),
+        }
+    }
+
+    #[test]
+    fn cancelled_tree_proof() {
+        let one = Scalar::one();
+        let path = MerklePath {
+            index: 0,
+            siblings: vec![one; 4],
+        };
+
+        let cancel = Cancel::default();
+        cancel.cancel();
+
+        let proof = Proof::prove_tree_cancellable(
+            one,
+            one,
+            one,
+            one,
+            one,
+            one,
+            one,
+            &path,
+            &[],
+            None,
+            None,
+            &cancel,
+        );
+        match proof {
+            Err(Error::Cancelled) => (),
+            r => panic!("Unexpected result of a cancelled proof: {:?}", r),
+        }
+    }
+
+    /// The header of an envelope, and its parameter set ID
+    fn envelope(version: u8, param_set: &[u8]) -> Vec<u8> {
+        let mut header = PROOF_MAGIC.to_vec();
+        header.push(version);
+
+        let mut writer = TlvWriter::new(vec![]);
+        writer.write(header.as_slice()).unwrap();
+        writer.write(param_set).unwrap();
+        writer.write(&[0x00; 32]).unwrap();
+        writer.into_inner()
+    }
+
+    #[test]
+    fn unknown_version_is_an_error() {
+        for &version in [0x00u8, 0x01, 0x03, 0xff].iter() {
+            match Proof::try_from(envelope(version, &param_set_id())) {
+                Err(Error::UnknownProofVersion(v)) => assert_eq!(version, v),
+                r => panic!("Unexpected result of version {}: {:?}", version, r),
             }
+        }
+
+        let mut other = param_set_id();
+        other[0] ^= 0x01;
+        match Proof::try_from(envelope(ProofVersion::V2 as u8, &other)) {
+            Err(Error::ParamSetMismatch) => (),
+            r => panic!("Unexpected result of another parameter set: {:?}", r),
+        }
 
-            // This function panics if the size is different from 32
-            let cr = CompressedRistretto::from_slice(t.as_slice());
-            t_c.push(cr);
+        // The header of a v2 envelope, without the R1CS proof
+        match Proof::try_from(envelope(ProofVersion::V2 as u8, &param_set_id())) {
+            Err(Error::Io(_)) => (),
+            r => panic!("Unexpected result of a truncated envelope: {:?}", r),
         }
+    }
+
+    /// Prove `pub_list[0]`, with the layout of `version`
+    fn prove_version(version: ProofVersion, list_len: usize) -> (Proof, BidWitness) {
+        let (d, k, seed) = (
+            Scalar::from(20000u64),
+            Scalar::from(7u64),
+            Scalar::from(9u64),
+        );
+        let w = BidWitness::derive(d, k, seed);
+        let pub_list = (0..list_len as u64)
+            .map(|i| Bid {
+                x: if i == 0 { w.x } else { Scalar::from(i) },
+            })
+            .collect();
+
+        let (proof, _) = Proof::prove_list(
+            version,
+            d,
+            k,
+            w.y_inv,
+            w.q,
+            w.z,
+            seed,
+            pub_list,
+            0,
+            &[],
+            None,
+            None,
+            &Cancel::default(),
+        )
+        .unwrap();
+
+        (proof, w)
+    }
+
+    #[test]
+    #[ignore]
+    fn v1_proofs_still_verify() {
+        let (proof, w) = prove_version(ProofVersion::V1, 2);
+        assert_eq!(4, proof.commitments.len());
+        assert_eq!(2, proof.t_c.len());
+
+        let bytes: Vec<u8> = proof.try_into().unwrap();
+        let proof = Proof::try_from(bytes).unwrap();
+        assert_eq!(ProofVersion::V1, proof.version);
+
+        let verify = Verify::new(
+            proof.proof,
+            proof.commitments,
+            proof.t_c,
+            proof.version,
+            proof.list_digest,
+            w.q,
+            w.z,
+            Scalar::from(9u64),
+            vec![w.x, Scalar::one()],
+            vec![],
+            None,
+            None,
+        );
+        verify.verify().unwrap();
 
-        Ok(Proof::new(proof, commitments, t_c))
+        // Made with the transcript of the v1 proofs, it binds no context or message
+        let mut v = verify.clone();
+        v.context = b"round 7".to_vec();
+        v.message = Some(b"block hash".to_vec());
+        v.verify().unwrap();
+
+        // The commitments do not match the other layout
+        let mut v = verify.clone();
+        v.version = ProofVersion::V2;
+        assert!(v.verify().is_err());
+    }
+
+    /// Run with `cargo test --release -- --ignored --nocapture v2_size` to print the sizes.
+    ///
+    /// The bytes saved are the ones of the table of the Readme.
+    #[test]
+    #[ignore]
+    fn v2_size() {
+        let saved = [
+            (1, -5),
+            (16, 506),
+            (173, 5844),
+            (174, 5814),
+            (202, 6766),
+            (1024, 34778),
+        ];
+
+        for &(list_len, saved) in saved.iter() {
+            let sizes: Vec<usize> = [ProofVersion::V1, ProofVersion::V2]
+                .iter()
+                .map(|&version| {
+                    let (proof, _) = prove_version(version, list_len);
+                    let bytes: Vec<u8> = proof.try_into().unwrap();
+                    let len = bytes.len();
+
+                    let proof = Proof::try_from(bytes).unwrap();
+                    assert_eq!(version, proof.version);
+                    assert_eq!(version == ProofVersion::V2, proof.list_digest.is_some());
+                    assert_eq!(version.commitments(), proof.commitments.len());
+                    assert_eq!(version.t_c_len(list_len), proof.t_c.len());
+
+                    len
+                })
+                .collect();
+
+            println!(
+                "{} bids: v1 {} bytes, v2 {} bytes, {} saved",
+                list_len,
+                sizes[0],
+                sizes[1],
+                sizes[0] as i64 - sizes[1] as i64
+            );
+            // The envelope outweighs the bid saved by a list of one, hence the negative saving
+            assert_eq!(saved, sizes[0] as i64 - sizes[1] as i64);
+        }
     }
 }
@@ -0,0 +1,93 @@
+use crate::Error;
+
+use std::io::Read;
+
+use curve25519_dalek::scalar::Scalar;
+use dusk_tlv::TlvReader;
+
+/// Decode a scalar from its canonical encoding, the only one accepted from the clients.
+///
+/// Any other 32 bytes would denote the same scalar as a canonical encoding, so they are
+/// rejected with `Error::NonCanonicalScalar` instead of being reduced.
+pub fn scalar_from_bytes(bytes: &[u8]) -> Result<Scalar, Error> {
+    if bytes.len() != 32 {
+        return Err(Error::io_invalid_data(
+            "Scalars Ristrettos can only be created from 32 bytes slices",
+        ));
+    }
+
+    let mut s = [0x00u8; 32];
+    s.copy_from_slice(bytes);
+
+    Scalar::from_canonical_bytes(s).ok_or(Error::NonCanonicalScalar)
+}
+
+/// Read the next frame of `reader` as a canonical scalar.
+pub fn read_scalar<R: Read>(reader: &mut TlvReader<R>) -> Result<Scalar, Error> {
+    let bytes = reader
+        .next()
+        .ok_or(Error::io_unexpected_eof("The scalar was not provided"))??;
+
+    scalar_from_bytes(bytes.as_slice())
+}
+
+/// Read the next list of `reader` as canonical scalars.
+pub fn read_scalar_list<R: Read>(reader: &mut TlvReader<R>) -> Result<Vec<Scalar>, Error> {
+    reader
+        .read_list::<Vec<u8>>()?
+        .iter()
+        .map(|bytes| scalar_from_bytes(bytes.as_slice()))
+        .collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    use dusk_tlv::TlvWriter;
+
+    /// Order of the scalar field, little endian
+    const L: [u8; 32] = [
+        0xed, 0xd3, 0xf5, 0x5c, 0x1a, 0x63, 0x12, 0x58, 0xd6, 0x9c, 0xf7, 0xa2, 0xde, 0xf9, 0xde,
+        0x14, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+        0x00, 0x10,
+    ];
+
+    #[test]
+    fn only_canonical_scalars() {
+        let seven = Scalar::from(7u64);
+        assert_eq!(seven, scalar_from_bytes(seven.as_bytes()).unwrap());
+
+        let mut max = L;
+        max[0] -= 1;
+        assert_eq!(-Scalar::one(), scalar_from_bytes(&max).unwrap());
+
+        // The same scalars, plus the order of the field
+        let mut seven_l = L;
+        seven_l[0] += 7;
+        for bytes in [L, seven_l, [0xff; 32]].iter() {
+            match scalar_from_bytes(bytes) {
+                Err(Error::NonCanonicalScalar) => (),
+                r => panic!("Unexpected result of a non-canonical scalar: {:?}", r),
+            }
+        }
+
+        assert!(scalar_from_bytes(&seven.as_bytes()[..31]).is_err());
+        assert!(scalar_from_bytes(&[0x00; 33]).is_err());
+    }
+
+    #[test]
+    fn read_scalars() {
+        let mut writer = TlvWriter::new(vec![]);
+        writer.write(Scalar::from(7u64).as_bytes()).unwrap();
+        writer
+            .write_list(&[Scalar::from(11u64).to_bytes().to_vec(), L.to_vec()])
+            .unwrap();
+        let bytes = writer.into_inner();
+
+        let mut reader = TlvReader::new(bytes.as_slice());
+        assert_eq!(Scalar::from(7u64), read_scalar(&mut reader).unwrap());
+        assert!(read_scalar_list(&mut reader).is_err());
+        assert!(read_scalar(&mut reader).is_err());
+    }
+}
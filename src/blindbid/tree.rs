@@ -0,0 +1,273 @@
+use super::{mimc_hash, Bid};
+use crate::Error;
+
+use curve25519_dalek::scalar::Scalar;
+
+/// MiMC Merkle tree of a bid list.
+///
+/// The leaves are the `x` of the bids, padded with zeroes up to a power of two, and every node
+/// is the MiMC hash of its left and right children.
+#[derive(Debug, Clone)]
+pub struct BidTree {
+    len: usize,
+    levels: Vec<Vec<Scalar>>,
+}
+
+/// Authentication path of a leaf of a `BidTree`.
+#[derive(Debug, Clone, PartialEq)]
+pub struct MerklePath {
+    pub index: u64,
+    /// Siblings of the nodes of the path, from the leaf up
+    pub siblings: Vec<Scalar>,
+}
+
+impl BidTree {
+    pub fn new(bids: &[Bid]) -> Self {
+        let depth = bids.len().next_power_of_two().trailing_zeros() as usize;
+
+        let mut leaves = vec![Scalar::zero(); 1 << depth];
+        for (leaf, bid) in leaves.iter_mut().zip(bids) {
+            *leaf = bid.x;
+        }
+
+        let mut levels = vec![leaves];
+        for _ in 0..depth {
+            let level: Vec<Scalar> = levels[levels.len() - 1]
+                .chunks(2)
+                .map(|pair| mimc_hash(&pair[0], &pair[1]))
+                .collect();
+
+            levels.push(level);
+        }
+
+        BidTree {
+            len: bids.len(),
+            levels,
+        }
+    }
+
+    /// Number of bids in the tree
+    pub fn len(&self) -> usize {
+        self.len
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.len == 0
+    }
+
+    /// Number of levels above the leaves
+    pub fn depth(&self) -> usize {
+        self.levels.len() - 1
+    }
+
+    pub fn root(&self) -> Scalar {
+        self.levels[self.depth()][0]
+    }
+
+    /// Replace the bid at `index`, rehashing only its path.
+    pub fn update(&mut self, index: usize, bid: Bid) -> Result<(), Error> {
+        if index >= self.len {
+            return Err(Error::Other(format!(
+                "The bid {} is out of a tree of {} bids",
+                index, self.len
+            )));
+        }
+
+        self.set_leaf(index, bid.x);
+
+        Ok(())
+    }
+
+    /// Append a bid, growing the tree by one level when it is full.
+    pub fn push(&mut self, bid: Bid) {
+        if self.len < self.levels[0].len() {
+            self.set_leaf(self.len, bid.x);
+            self.len += 1;
+        } else {
+            let mut bids: Vec<Bid> = self.levels[0][..self.len]
+                .iter()
+                .map(|&x| Bid { x })
+                .collect();
+            bids.push(bid);
+
+            *self = BidTree::new(bids.as_slice());
+        }
+    }
+
+    pub fn path(&self, index: usize) -> Result<MerklePath, Error> {
+        if index >= self.len {
+            return Err(Error::Other(format!(
+                "The bid {} is out of a tree of {} bids",
+                index, self.len
+            )));
+        }
+
+        let siblings = self.levels[..self.depth()]
+            .iter()
+            .enumerate()
+            .map(|(level, nodes)| nodes[(index >> level) ^ 1])
+            .collect();
+
+        Ok(MerklePath {
+            index: index as u64,
+            siblings,
+        })
+    }
+
+    fn set_leaf(&mut self, index: usize, x: Scalar) {
+        self.levels[0][index] = x;
+
+        let mut index = index;
+        for level in 1..self.levels.len() {
+            index >>= 1;
+
+            let children = &self.levels[level - 1];
+            let node = mimc_hash(&children[2 * index], &children[2 * index + 1]);
+
+            self.levels[level][index] = node;
+        }
+    }
+}
+
+impl From<Vec<Bid>> for BidTree {
+    fn from(bids: Vec<Bid>) -> Self {
+        BidTree::new(bids.as_slice())
+    }
+}
+
+impl MerklePath {
+    /// Root of the tree the path leads to from `leaf`.
+    pub fn root(&self, leaf: &Scalar) -> Scalar {
+        self.siblings
+            .iter()
+            .enumerate()
+            .fold(*leaf, |node, (level, sibling)| {
+                if (self.index >> level) & 1 == 1 {
+                    mimc_hash(sibling, &node)
+                } else {
+                    mimc_hash(&node, sibling)
+                }
+            })
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::{BidWitness, Proof, VerifyTree};
+
+    fn bids(n: u64) -> Vec<Bid> {
+        (0..n)
+            .map(|i| Bid {
+                x: Scalar::from(i + 1),
+            })
+            .collect()
+    }
+
+    #[test]
+    fn paths_lead_to_root() {
+        let tree = BidTree::from(bids(5));
+
+        assert_eq!(3, tree.depth());
+        for i in 0..tree.len() {
+            let path = tree.path(i).unwrap();
+            assert_eq!(tree.root(), path.root(&Scalar::from(i as u64 + 1)));
+        }
+
+        assert!(tree.path(5).is_err());
+    }
+
+    #[test]
+    fn update_and_push_match_rebuild() {
+        let mut tree = BidTree::from(bids(4));
+
+        tree.update(
+            2,
+            Bid {
+                x: Scalar::from(9u64),
+            },
+        )
+        .unwrap();
+        tree.push(Bid {
+            x: Scalar::from(5u64),
+        });
+        tree.push(Bid {
+            x: Scalar::from(6u64),
+        });
+
+        let mut expected = bids(6);
+        expected[2].x = Scalar::from(9u64);
+        let expected = BidTree::from(expected);
+
+        assert_eq!(expected.depth(), tree.depth());
+        assert_eq!(expected.root(), tree.root());
+    }
+
+    #[test]
+    #[ignore]
+    fn tree_proof_verifies() {
+        let (d, k, seed) = (
+            Scalar::from(20000u64),
+            Scalar::from(7u64),
+            Scalar::from(9u64),
+        );
+        let w = BidWitness::derive(d, k, seed);
+
+        let mut tree = BidTree::from(bids(1000));
+        tree.update(617, Bid { x: w.x }).unwrap();
+        let path = tree.path(617).unwrap();
+
+        let (proof, _) = Proof::prove_tree(
+            d,
+            k,
+            w.y_inv,
+            w.q,
+            w.z,
+            seed,
+            tree.root(),
+            &path,
+            b"round 1",
+            None,
+            None,
+        )
+        .unwrap();
+        assert!(proof.t_c.is_empty());
+
+        let verify = VerifyTree::new(
+            proof.proof.clone(),
+            proof.commitments.clone(),
+            proof.version,
+            proof.list_digest,
+            w.q,
+            w.z,
+            seed,
+            tree.root(),
+            tree.depth(),
+            b"round 1".to_vec(),
+            None,
+            None,
+        );
+        verify.verify().unwrap();
+
+        let mut other_round = verify.clone();
+        other_round.context = b"round 2".to_vec();
+        assert!(other_round.verify().is_err());
+
+        let verify = VerifyTree::new(
+            proof.proof,
+            proof.commitments,
+            proof.version,
+            // Without the digest, the other root is rejected by the circuit
+            None,
+            w.q,
+            w.z,
+            seed,
+            Scalar::from(1u64),
+            tree.depth(),
+            b"round 1".to_vec(),
+            None,
+            None,
+        );
+        assert!(verify.verify().is_err());
+    }
+}
@@ -1,15 +1,22 @@
-use super::{generate_cs_transcript, Proof, CONSTANTS};
-use crate::gadgets::proof_gadget;
+use super::{
+    bind_list_inputs, bind_tree_inputs, check_commitments, check_list_len, generate_cs_transcript,
+    generators_capacity, list_digest, params, read_scalar, read_scalar_list, tree_digest,
+    tree_generators_capacity, Proof, ProofVersion, CONSTANTS,
+};
+use crate::gadgets::{proof_gadget, tree_proof_gadget};
 use crate::Error;
 
+use std::cmp;
 use std::convert::TryFrom;
 use std::io::Read;
 
-use bulletproofs::r1cs::Verifier;
-use bulletproofs::r1cs::{LinearCombination, R1CSProof, Variable};
+use bulletproofs::r1cs::{batch_verify, R1CSError, Verifier};
+use bulletproofs::r1cs::{ConstraintSystem, LinearCombination, R1CSProof, Variable};
 use curve25519_dalek::ristretto::CompressedRistretto;
 use curve25519_dalek::scalar::Scalar;
 use dusk_tlv::TlvReader;
+use merlin::Transcript;
+use rand::thread_rng;
 use serde::{Deserialize, Serialize};
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -17,10 +24,20 @@ pub struct Verify {
     pub proof: R1CSProof,
     pub commitments: Vec<CompressedRistretto>,
     pub t_c: Vec<CompressedRistretto>,
+    /// Layout of the proof, telling the commitments and `t_c` it has
+    pub version: ProofVersion,
+    /// Digest of the bid list the proof was made against, if the proof carries it
+    pub list_digest: Option<[u8; 32]>,
     pub score: Scalar,
     pub z_img: Scalar,
     pub seed: Scalar,
     pub pub_list: Vec<Scalar>,
+    /// Context the proof was made for, see `Proof::prove`
+    pub context: Vec<u8>,
+    /// Message signed by the proof, if any
+    pub message: Option<Vec<u8>>,
+    /// Commitment the bid was locked with, if the proof must be about it
+    pub d_commitment: Option<CompressedRistretto>,
 }
 
 impl Verify {
@@ -28,24 +45,55 @@ impl Verify {
         proof: R1CSProof,
         commitments: Vec<CompressedRistretto>,
         t_c: Vec<CompressedRistretto>,
+        version: ProofVersion,
+        list_digest: Option<[u8; 32]>,
         score: Scalar,
         z_img: Scalar,
         seed: Scalar,
         pub_list: Vec<Scalar>,
+        context: Vec<u8>,
+        message: Option<Vec<u8>>,
+        d_commitment: Option<CompressedRistretto>,
     ) -> Self {
         Verify {
             proof,
             commitments,
             t_c,
+            version,
+            list_digest,
             score,
             z_img,
             seed,
             pub_list,
+            context,
+            message,
+            d_commitment,
         }
     }
 
+    /// Check the shape of the proof against the public list, before any constraint system is
+    /// built on it.
+    pub fn validate(&self) -> Result<(), Error> {
+        check_shape(
+            self.version,
+            self.commitments.len(),
+            self.t_c.len(),
+            self.pub_list.len(),
+        )
+    }
+
+    /// Verify the proof against the public inputs, and against `d_commitment` if any.
+    ///
+    /// A proof made against another bid list fails before the constraint system is built, if
+    /// it carries the digest of its list.
     pub fn verify(&self) -> Result<(), Error> {
-        let (pc_gens, bp_gens, mut transcript) = generate_cs_transcript();
+        self.validate()?;
+        check_d_commitment(&self.commitments, self.d_commitment.as_ref())?;
+        check_list_digest(self.list_digest.as_ref(), list_digest(&self.pub_list))?;
+
+        let (params, mut transcript) =
+            generate_cs_transcript(generators_capacity(self.pub_list.len())?);
+        self.bind(&mut transcript);
 
         // 1. Create a verifier
         let mut verifier = Verifier::new(&mut transcript);
@@ -63,6 +111,90 @@ impl Verify {
             .map(|v| verifier.commit(*v).into())
             .collect();
 
+        // 3. Build a CS
+        self.gadget(&mut verifier, vars, t_c_v)?;
+
+        // 4. Verify the proof
+        Ok(verifier.verify(&self.proof, &params.pc_gens, &params.bp_gens)?)
+    }
+
+    /// Verify all the proofs of `batch` at once, with randomized batch verification.
+    ///
+    /// If the batch fails, every proof is verified on its own and the individual results are
+    /// returned, in the same order of `batch`.
+    pub fn verify_batch(batch: &[Verify]) -> Result<(), Vec<Result<(), Error>>> {
+        Verify::try_verify_batch(batch).map_err(|e| {
+            debug!("Batch verification failed: {}", e);
+            batch.iter().map(|v| v.verify()).collect()
+        })
+    }
+
+    fn try_verify_batch(batch: &[Verify]) -> Result<(), Error> {
+        if batch.is_empty() {
+            return Ok(());
+        }
+
+        let mut capacity = 0;
+        for v in batch {
+            v.validate()?;
+            check_d_commitment(&v.commitments, v.d_commitment.as_ref())?;
+            check_list_digest(v.list_digest.as_ref(), list_digest(&v.pub_list))?;
+            capacity = cmp::max(capacity, generators_capacity(v.pub_list.len())?);
+        }
+
+        let params = params(capacity);
+        let mut transcripts: Vec<Transcript> = batch
+            .iter()
+            .map(|v| {
+                let mut transcript = generate_cs_transcript(capacity).1;
+                v.bind(&mut transcript);
+                transcript
+            })
+            .collect();
+
+        let instances = batch
+            .iter()
+            .zip(transcripts.iter_mut())
+            .map(|(v, transcript)| {
+                let mut verifier = Verifier::new(transcript);
+
+                let vars: Vec<_> = v.commitments.iter().map(|c| verifier.commit(*c)).collect();
+                let t_c_v: Vec<Variable> =
+                    v.t_c.iter().map(|c| verifier.commit(*c).into()).collect();
+
+                v.gadget(&mut verifier, vars, t_c_v)?;
+
+                Ok((verifier, &v.proof))
+            })
+            .collect::<Result<Vec<_>, Error>>()?;
+
+        Ok(batch_verify(
+            &mut thread_rng(),
+            instances,
+            &params.pc_gens,
+            &params.bp_gens,
+        )?)
+    }
+
+    fn bind(&self, transcript: &mut Transcript) {
+        bind_list_inputs(
+            transcript,
+            self.version,
+            self.context.as_slice(),
+            self.message.as_ref().map(Vec::as_slice),
+            &self.score,
+            &self.z_img,
+            &self.seed,
+            self.pub_list.as_slice(),
+        );
+    }
+
+    fn gadget<CS: ConstraintSystem>(
+        &self,
+        cs: &mut CS,
+        vars: Vec<Variable>,
+        t_c_v: Vec<Variable>,
+    ) -> Result<(), Error> {
         // public list of numbers
         let l_v: Vec<LinearCombination> = self
             .pub_list
@@ -70,22 +202,28 @@ impl Verify {
             .map(|&x| Scalar::from(x).into())
             .collect::<Vec<_>>();
 
-        // 3. Build a CS
+        // The toggles of `ProofVersion::V2` are allocated in the circuit instead of committed
+        let toggles = match self.version {
+            ProofVersion::V1 => t_c_v,
+            ProofVersion::V2 => (0..self.pub_list.len())
+                .map(|_| cs.allocate(None))
+                .collect::<Result<_, _>>()?,
+        };
+
         proof_gadget(
-            &mut verifier,
+            cs,
             vars[0].into(),
             vars[1].into(),
-            vars[3].into(),
+            vars[vars.len() - 1].into(),
             self.score.into(),
             self.z_img.into(),
             self.seed.into(),
             &*CONSTANTS,
-            t_c_v,
+            toggles,
             l_v,
-        );
+        )?;
 
-        // 4. Verify the proof
-        Ok(verifier.verify(&self.proof, &pc_gens, &bp_gens)?)
+        Ok(())
     }
 
     pub fn try_from_reader_variables<R: Read>(reader: R) -> Result<Self, Error> {
@@ -95,35 +233,458 @@ impl Verify {
             .next()
             .ok_or(Error::io_unexpected_eof("No proof data was provided"))??;
         let proof = Proof::try_from(proof)?;
-        let (proof, commitments, t_c) = (proof.proof, proof.commitments, proof.t_c);
 
-        let score = Deserialize::deserialize(&mut reader)?;
-        let z_img = Deserialize::deserialize(&mut reader)?;
-        let seed = Deserialize::deserialize(&mut reader)?;
+        let score = read_scalar(&mut reader)?;
+        let z_img = read_scalar(&mut reader)?;
+        let seed = read_scalar(&mut reader)?;
+        let pub_list = read_scalar_list(&mut reader)?;
 
-        let mut pub_list = vec![];
-        for bytes in reader.read_list::<Vec<u8>>()? {
-            if bytes.len() != 32 {
+        // The context is optional, an absent one being empty, and so are the message and the
+        // expected d commitment after it
+        let context = reader.next().transpose()?.unwrap_or_default();
+        let mut trailing = vec![];
+        while let Some(frame) = reader.next().transpose()? {
+            trailing.push(frame);
+        }
+        let (message, d_commitment) = message_and_d_commitment(trailing)?;
+
+        Ok(Verify::new(
+            proof.proof,
+            proof.commitments,
+            proof.t_c,
+            proof.version,
+            proof.list_digest,
+            score,
+            z_img,
+            seed,
+            pub_list,
+            context,
+            message,
+            d_commitment,
+        ))
+    }
+}
+
+/// Fail unless a proof of `version` with `commitments` commitments and `t_c` toggle commitments
+/// fits a bid list of `list_len` entries.
+fn check_shape(
+    version: ProofVersion,
+    commitments: usize,
+    t_c: usize,
+    list_len: usize,
+) -> Result<(), Error> {
+    check_commitments(commitments, version)?;
+    check_list_len(list_len)?;
+
+    if t_c != version.t_c_len(list_len) {
+        return Err(Error::ListLenMismatch(t_c, list_len));
+    }
+
+    Ok(())
+}
+
+/// Split the frames after the context of a verify request into the message and the expected d
+/// commitment.
+///
+/// The frames are the message alone, if any, or three fixed slots when the d commitment is
+/// expected: the presence of the message (`0x01`, or `0x00` if absent), the message, empty if
+/// absent, and the d commitment.
+fn message_and_d_commitment(
+    mut frames: Vec<Vec<u8>>,
+) -> Result<(Option<Vec<u8>>, Option<CompressedRistretto>), Error> {
+    match frames.len() {
+        0 | 1 => Ok((frames.pop(), None)),
+        3 => {
+            let d_commitment = frames.pop().unwrap_or_default();
+            if d_commitment.len() != 32 {
                 return Err(Error::io_invalid_data(
-                    "Scalars Ristrettos can only be created from 32 bytes slices",
+                    "The d commitment must be a 32 bytes compressed Ristretto",
                 ));
             }
 
-            let mut p = [0x00u8; 32];
-            p.copy_from_slice(bytes.as_slice());
+            let message = frames.pop().unwrap_or_default();
+            let message = match frames[0].as_slice() {
+                [0x00] if message.is_empty() => None,
+                [0x01] => Some(message),
+                _ => {
+                    return Err(Error::io_invalid_data(
+                        "The presence of the message must be 0x01, or 0x00 with an empty slot",
+                    ))
+                }
+            };
 
-            let p = Scalar::from_bits(p);
-            pub_list.push(p);
+            Ok((
+                message,
+                Some(CompressedRistretto::from_slice(d_commitment.as_slice())),
+            ))
         }
+        n => Err(Error::io_invalid_data(format!(
+            "{} frames follow the context, instead of the message and the d commitment",
+            n
+        ))),
+    }
+}
 
-        Ok(Verify::new(
+/// Verification of a proof created with `Proof::prove_tree`.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct VerifyTree {
+    pub proof: R1CSProof,
+    pub commitments: Vec<CompressedRistretto>,
+    pub version: ProofVersion,
+    /// Digest of the root and depth of the bid tree, see `tree_digest`
+    pub list_digest: Option<[u8; 32]>,
+    pub score: Scalar,
+    pub z_img: Scalar,
+    pub seed: Scalar,
+    pub root: Scalar,
+    pub depth: usize,
+    pub context: Vec<u8>,
+    pub message: Option<Vec<u8>>,
+    pub d_commitment: Option<CompressedRistretto>,
+}
+
+impl VerifyTree {
+    pub fn new(
+        proof: R1CSProof,
+        commitments: Vec<CompressedRistretto>,
+        version: ProofVersion,
+        list_digest: Option<[u8; 32]>,
+        score: Scalar,
+        z_img: Scalar,
+        seed: Scalar,
+        root: Scalar,
+        depth: usize,
+        context: Vec<u8>,
+        message: Option<Vec<u8>>,
+        d_commitment: Option<CompressedRistretto>,
+    ) -> Self {
+        VerifyTree {
             proof,
             commitments,
-            t_c,
+            version,
+            list_digest,
             score,
             z_img,
             seed,
+            root,
+            depth,
+            context,
+            message,
+            d_commitment,
+        }
+    }
+
+    pub fn verify(&self) -> Result<(), Error> {
+        check_commitments(self.commitments.len(), self.version)?;
+        check_d_commitment(&self.commitments, self.d_commitment.as_ref())?;
+        check_list_digest(
+            self.list_digest.as_ref(),
+            tree_digest(&self.root, self.depth),
+        )?;
+
+        let capacity = tree_generators_capacity(self.depth)?;
+        let (params, mut transcript) = generate_cs_transcript(capacity);
+        bind_tree_inputs(
+            &mut transcript,
+            self.context.as_slice(),
+            self.message.as_ref().map(Vec::as_slice),
+            &self.score,
+            &self.z_img,
+            &self.seed,
+            &self.root,
+            self.depth,
+        );
+
+        // 1. Create a verifier
+        let mut verifier = Verifier::new(&mut transcript);
+
+        // 2. Commit high-level variables
+        let vars: Vec<_> = self
+            .commitments
+            .iter()
+            .map(|v| verifier.commit(*v))
+            .collect();
+
+        // 3. Build a CS
+        tree_proof_gadget(
+            &mut verifier,
+            vars[0].into(),
+            vars[1].into(),
+            vars[vars.len() - 1].into(),
+            self.score.into(),
+            self.z_img.into(),
+            self.seed.into(),
+            &*CONSTANTS,
+            vec![None; self.depth],
+            self.root.into(),
+        )?;
+
+        // 4. Verify the proof
+        Ok(verifier.verify(&self.proof, &params.pc_gens, &params.bp_gens)?)
+    }
+}
+
+/// Fail the verification if the proof does not commit to d with the `expected` commitment.
+fn check_d_commitment(
+    commitments: &[CompressedRistretto],
+    expected: Option<&CompressedRistretto>,
+) -> Result<(), Error> {
+    match expected {
+        Some(expected) if commitments.first() != Some(expected) => {
+            Err(Error::R1CS(R1CSError::VerificationError))
+        }
+        _ => Ok(()),
+    }
+}
+
+/// Fail the verification if the proof carries the digest of a bid list other than `digest`.
+fn check_list_digest(expected: Option<&[u8; 32]>, digest: [u8; 32]) -> Result<(), Error> {
+    match expected {
+        Some(expected) if *expected != digest => Err(Error::R1CS(R1CSError::VerificationError)),
+        _ => Ok(()),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::{Bid, BidWitness, Blindings};
+
+    /// A valid proof made in `context`, over `message`, with the blindings used
+    fn proven(context: &[u8], message: Option<&[u8]>) -> (Verify, Blindings) {
+        let (d, k, seed) = (
+            Scalar::from(20000u64),
+            Scalar::from(7u64),
+            Scalar::from(9u64),
+        );
+        let w = BidWitness::derive(d, k, seed);
+        let pub_list = vec![Bid { x: w.x }, Bid { x: Scalar::one() }];
+
+        let (proof, blindings) = Proof::prove(
+            d,
+            k,
+            w.y_inv,
+            w.q,
+            w.z,
+            seed,
+            pub_list.clone(),
+            0,
+            context,
+            message,
+            None,
+        )
+        .unwrap();
+
+        let verify = Verify::new(
+            proof.proof,
+            proof.commitments,
+            proof.t_c,
+            proof.version,
+            proof.list_digest,
+            w.q,
+            w.z,
+            seed,
+            pub_list.iter().map(|b| b.x).collect(),
+            context.to_vec(),
+            message.map(|m| m.to_vec()),
+            None,
+        );
+
+        (verify, blindings)
+    }
+
+    #[test]
+    fn shape_of_the_proofs() {
+        assert!(check_shape(ProofVersion::V2, 3, 0, 2).is_ok());
+        assert!(check_shape(ProofVersion::V1, 4, 2, 2).is_ok());
+
+        // Fewer than three commitments
+        match check_shape(ProofVersion::V2, 2, 0, 2) {
+            Err(Error::CommitmentCount(2, 3)) => (),
+            r => panic!("Unexpected result {:?}", r),
+        }
+
+        // The commitments of the other layout
+        match check_shape(ProofVersion::V1, 3, 2, 2) {
+            Err(Error::CommitmentCount(3, 4)) => (),
+            r => panic!("Unexpected result {:?}", r),
+        }
+
+        // The toggles of a v2 proof are not committed, and every toggle of a v1 proof is
+        match check_shape(ProofVersion::V2, 3, 2, 2) {
+            Err(Error::ListLenMismatch(2, 2)) => (),
+            r => panic!("Unexpected result {:?}", r),
+        }
+        match check_shape(ProofVersion::V1, 4, 1, 2) {
+            Err(Error::ListLenMismatch(1, 2)) => (),
+            r => panic!("Unexpected result {:?}", r),
+        }
+
+        match check_shape(ProofVersion::V2, 3, 0, 0) {
+            Err(Error::EmptyList) => (),
+            r => panic!("Unexpected result {:?}", r),
+        }
+    }
+
+    #[test]
+    fn message_and_d_commitment_slots() {
+        let (m, c) = (b"block hash".to_vec(), vec![0x07; 32]);
+        let point = CompressedRistretto::from_slice(c.as_slice());
+        let (absent, present) = (vec![0x00], vec![0x01]);
+
+        let split = |frames: &[&Vec<u8>]| {
+            message_and_d_commitment(frames.iter().map(|&f| f.clone()).collect())
+        };
+
+        assert_eq!((None, None), split(&[]).unwrap());
+        assert_eq!((Some(m.clone()), None), split(&[&m]).unwrap());
+        assert_eq!(
+            (Some(m.clone()), Some(point)),
+            split(&[&present, &m, &c]).unwrap()
+        );
+        assert_eq!(
+            (Some(vec![]), Some(point)),
+            split(&[&present, &vec![], &c]).unwrap()
+        );
+
+        // No message differs from an empty one
+        assert_eq!((None, Some(point)), split(&[&absent, &vec![], &c]).unwrap());
+
+        assert!(split(&[&m, &c]).is_err());
+        assert!(split(&[&absent, &m, &c]).is_err());
+        assert!(split(&[&vec![0x02], &vec![], &c]).is_err());
+        assert!(split(&[&present, &m, &c[..31].to_vec()]).is_err());
+        assert!(split(&[&present, &m, &c, &c]).is_err());
+    }
+
+    #[test]
+    #[ignore]
+    fn malformed_verify_is_an_error() {
+        let (verify, _) = proven(&[], None);
+        verify.verify().unwrap();
+
+        // Fewer than three commitments
+        let mut v = verify.clone();
+        v.commitments.truncate(2);
+        match v.verify() {
+            Err(Error::CommitmentCount(2, 3)) => (),
+            r => panic!("Unexpected result {:?}", r),
+        }
+        assert!(Verify::verify_batch(&[v]).is_err());
+
+        // The commitments of the other layout
+        let mut v = verify.clone();
+        v.version = ProofVersion::V1;
+        match v.verify() {
+            Err(Error::CommitmentCount(3, 4)) => (),
+            r => panic!("Unexpected result {:?}", r),
+        }
+
+        // The toggles of a v2 proof are not committed
+        let mut v = verify.clone();
+        v.t_c = v.commitments[..2].to_vec();
+        match v.verify() {
+            Err(Error::ListLenMismatch(2, 2)) => (),
+            r => panic!("Unexpected result {:?}", r),
+        }
+
+        // Empty public list
+        let mut v = verify.clone();
+        v.pub_list.clear();
+        match v.verify() {
+            Err(Error::EmptyList) => (),
+            r => panic!("Unexpected result {:?}", r),
+        }
+
+        let tree = VerifyTree::new(
+            verify.proof,
+            verify.commitments[..2].to_vec(),
+            verify.version,
+            None,
+            verify.score,
+            verify.z_img,
+            verify.seed,
+            Scalar::zero(),
+            1,
+            vec![],
+            None,
+            None,
+        );
+        match tree.verify() {
+            Err(Error::CommitmentCount(2, 3)) => (),
+            r => panic!("Unexpected result {:?}", r),
+        }
+    }
+
+    #[test]
+    #[ignore]
+    fn proof_is_bound_to_its_inputs() {
+        let (verify, _) = proven(b"chain 1, round 7, step 2", Some(&b"block hash"[..]));
+        verify.verify().unwrap();
+        assert!(Verify::verify_batch(&[verify.clone()]).is_ok());
+
+        let mut v = verify.clone();
+        v.context = b"chain 1, round 8, step 2".to_vec();
+        assert!(v.verify().is_err());
+        assert!(Verify::verify_batch(&[v]).is_err());
+
+        // The proof signs its message
+        let mut v = verify.clone();
+        v.message = Some(b"other block hash".to_vec());
+        assert!(v.verify().is_err());
+
+        let mut v = verify.clone();
+        v.message = None;
+        assert!(v.verify().is_err());
+
+        // The digest of another bid list is rejected before the circuit
+        let mut v = verify.clone();
+        v.list_digest = Some(list_digest(&v.pub_list[..1]));
+        assert!(v.verify().is_err());
+        assert!(Verify::verify_batch(&[v]).is_err());
+
+        let mut v = verify.clone();
+        v.list_digest = None;
+        v.verify().unwrap();
+    }
+
+    #[test]
+    #[ignore]
+    fn proof_commits_to_the_locked_bid() {
+        let (verify, blindings) = proven(&[], None);
+        let d = Scalar::from(20000u64);
+
+        let mut v = verify.clone();
+        v.d_commitment = Some(blindings.d_commitment(d));
+        v.verify().unwrap();
+
+        // Another bid, or the same bid locked with another blinding
+        v.d_commitment = Some(blindings.d_commitment(d + Scalar::one()));
+        assert!(v.verify().is_err());
+        v.d_commitment = Some(Blindings::random().d_commitment(d));
+        assert!(v.verify().is_err());
+        assert!(Verify::verify_batch(&[v]).is_err());
+
+        // The proof is made with the blindings of the caller
+        let w = BidWitness::derive(d, Scalar::from(7u64), verify.seed);
+        let locked = Blindings::random();
+        let pub_list = vec![Bid { x: w.x }];
+
+        let (proof, used) = Proof::prove(
+            d,
+            Scalar::from(7u64),
+            w.y_inv,
+            w.q,
+            w.z,
+            verify.seed,
             pub_list,
-        ))
+            0,
+            &[],
+            None,
+            Some(locked),
+        )
+        .unwrap();
+        assert_eq!(locked, used);
+        assert_eq!(locked.d_commitment(d), proof.commitments[0]);
     }
 }
@@ -0,0 +1,118 @@
+use super::mimc_hash;
+
+use curve25519_dalek::scalar::Scalar;
+
+/// The values of a bid that the blind bid circuit recomputes.
+///
+/// `y_inv`, `q` and `z` are the inputs of `Proof::prove`, while `x` is the value the bid is
+/// registered with in the public list.
+#[derive(Debug, Clone, Copy, PartialEq)]
+pub struct BidWitness {
+    pub m: Scalar,
+    pub x: Scalar,
+    pub y: Scalar,
+    pub y_inv: Scalar,
+    pub z: Scalar,
+    pub q: Scalar,
+}
+
+impl BidWitness {
+    /// Derive the witness of a bid with value `d` and secret `k` for the round `seed`.
+    pub fn derive(d: Scalar, k: Scalar, seed: Scalar) -> Self {
+        let m = mimc_hash(&k, &Scalar::zero());
+        let x = mimc_hash(&d, &m);
+        let y = mimc_hash(&seed, &x);
+        let z = mimc_hash(&seed, &m);
+
+        let y_inv = y.invert();
+        let q = d * y_inv;
+
+        BidWitness {
+            m,
+            x,
+            y,
+            y_inv,
+            z,
+            q,
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::{Bid, Proof, Verify};
+
+    fn hex(s: &Scalar) -> String {
+        s.as_bytes().iter().map(|b| format!("{:02x}", b)).collect()
+    }
+
+    #[test]
+    fn derive_known_answer() {
+        let w = BidWitness::derive(Scalar::from(1u64), Scalar::from(2u64), Scalar::from(3u64));
+
+        assert_eq!(
+            "e9c12933df0565e65eabf6436296300b1a8f9eb7355ebc1af7d0661cd8f23805",
+            hex(&w.m)
+        );
+        assert_eq!(
+            "5e395c9ec4619463da3f175499c6299c7965f374dd5a2f2c54540515b275c40d",
+            hex(&w.x)
+        );
+        assert_eq!(
+            "1ad9747f15d2da203b41f5ed17da87eb63175ceae61072128fba807f261f0607",
+            hex(&w.y)
+        );
+        assert_eq!(
+            "b9c78d935706cae2cc9ca48b2bca76884358a7d0a4a2366605959ade22a6be07",
+            hex(&w.z)
+        );
+        assert_eq!(Scalar::one(), w.y * w.y_inv);
+        assert_eq!(w.y_inv, w.q);
+    }
+
+    #[test]
+    #[ignore]
+    fn derived_witness_proves() {
+        let (d, k, seed) = (
+            Scalar::from(20000u64),
+            Scalar::from(7u64),
+            Scalar::from(9u64),
+        );
+        let w = BidWitness::derive(d, k, seed);
+
+        let mut pub_list: Vec<Bid> = (1..8u64).map(|i| Bid { x: Scalar::from(i) }).collect();
+        pub_list.insert(3, Bid { x: w.x });
+
+        let (proof, _) = Proof::prove(
+            d,
+            k,
+            w.y_inv,
+            w.q,
+            w.z,
+            seed,
+            pub_list.clone(),
+            3,
+            &[],
+            None,
+            None,
+        )
+        .unwrap();
+
+        let verify = Verify::new(
+            proof.proof,
+            proof.commitments,
+            proof.t_c,
+            proof.version,
+            proof.list_digest,
+            w.q,
+            w.z,
+            seed,
+            pub_list.iter().map(|b| b.x).collect(),
+            vec![],
+            None,
+            None,
+        );
+        verify.verify().unwrap();
+    }
+}
@@ -0,0 +1,73 @@
+use crate::Error;
+
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::sync::Arc;
+use std::time::Instant;
+
+/// Cancellation of a request, checked between the phases of its resolution.
+///
+/// The clones of a `Cancel` share its flag, so cancelling any of them cancels all.
+#[derive(Debug, Clone, Default)]
+pub struct Cancel {
+    deadline: Option<Instant>,
+    cancelled: Arc<AtomicBool>,
+}
+
+impl Cancel {
+    /// The request is abandoned once `deadline` passes, if any.
+    pub fn new(deadline: Option<Instant>) -> Self {
+        Cancel::with_flag(deadline, Arc::new(AtomicBool::new(false)))
+    }
+
+    /// The request is abandoned once `deadline` passes, or once `cancelled` is set.
+    pub fn with_flag(deadline: Option<Instant>, cancelled: Arc<AtomicBool>) -> Self {
+        Cancel {
+            deadline,
+            cancelled,
+        }
+    }
+
+    pub fn cancel(&self) {
+        self.cancelled.store(true, Ordering::SeqCst);
+    }
+
+    /// Fail with `Error::Cancelled` or `Error::DeadlineExceeded` if the request was abandoned.
+    pub fn check(&self) -> Result<(), Error> {
+        if self.cancelled.load(Ordering::SeqCst) {
+            return Err(Error::Cancelled);
+        }
+
+        match self.deadline {
+            Some(deadline) if Instant::now() >= deadline => Err(Error::DeadlineExceeded),
+            _ => Ok(()),
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    use std::time::Duration;
+
+    #[test]
+    fn deadline_and_flag() {
+        assert!(Cancel::default().check().is_ok());
+
+        let past = Cancel::new(Some(Instant::now() - Duration::from_millis(1)));
+        match past.check() {
+            Err(Error::DeadlineExceeded) => (),
+            r => panic!("Unexpected result of a past deadline: {:?}", r),
+        }
+
+        let cancel = Cancel::new(Some(Instant::now() + Duration::from_secs(60)));
+        let clone = cancel.clone();
+        assert!(clone.check().is_ok());
+
+        cancel.cancel();
+        match clone.check() {
+            Err(Error::Cancelled) => (),
+            r => panic!("Unexpected result of a cancelled request: {:?}", r),
+        }
+    }
+}
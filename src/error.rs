@@ -2,6 +2,8 @@ use std::error::Error as StdError;
 use std::fmt;
 use std::io::{self, Error as IoError};
 
+use crate::blindbid::{MAX_LIST_LEN, MAX_TREE_DEPTH};
+
 use bulletproofs::r1cs::R1CSError;
 use dusk_tlv::Error as TlvError;
 
@@ -17,14 +19,60 @@ macro_rules! from_error {
 
 #[derive(Debug)]
 pub enum Error {
+    Busy,
+    Cancelled,
+    CommitmentCount(usize, usize),
+    DeadlineExceeded,
+    EmptyList,
     Io(IoError),
+    ListLenMismatch(usize, usize),
+    ListTooLarge(usize),
+    NonCanonicalScalar,
+    NotReady,
     Other(String),
+    ParamSetMismatch,
     R1CS(R1CSError),
+    ShuttingDown,
     Tlv(TlvError),
+    ToggleOutOfRange(u64, usize),
+    TreeTooDeep(usize),
     UnexpectedEof,
+    UnknownJob(u64),
+    UnknownOperation(u8),
+    UnknownProofVersion(u8),
 }
 
+/// Status code of a successful response
+pub const STATUS_OK: u8 = 0x00;
+
 impl Error {
+    /// Stable status code reported to the clients for this error.
+    pub fn code(&self) -> u8 {
+        match self {
+            Error::Io(_) => 0x01,
+            Error::Tlv(_) => 0x02,
+            Error::R1CS(_) => 0x03,
+            Error::Other(_) => 0x04,
+            Error::UnexpectedEof => 0x05,
+            Error::ListTooLarge(_) => 0x06,
+            Error::TreeTooDeep(_) => 0x07,
+            Error::Busy => 0x08,
+            Error::UnknownJob(_) => 0x09,
+            Error::DeadlineExceeded => 0x0a,
+            Error::Cancelled => 0x0b,
+            Error::NotReady => 0x0c,
+            Error::ShuttingDown => 0x0d,
+            Error::CommitmentCount(_, _) => 0x0e,
+            Error::EmptyList => 0x0f,
+            Error::ListLenMismatch(_, _) => 0x10,
+            Error::ToggleOutOfRange(_, _) => 0x11,
+            Error::NonCanonicalScalar => 0x12,
+            Error::UnknownProofVersion(_) => 0x13,
+            Error::ParamSetMismatch => 0x14,
+            Error::UnknownOperation(_) => 0x15,
+        }
+    }
+
     pub fn io_unexpected_eof<S: ToString>(description: S) -> Self {
         let description = description.to_string();
         Error::Io(io::Error::new(io::ErrorKind::UnexpectedEof, description))
@@ -39,11 +87,47 @@ impl Error {
 impl fmt::Display for Error {
     fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
         match self {
+            Error::Busy => write!(f, "The server is busy, retry later"),
+            Error::Cancelled => write!(f, "The request was cancelled"),
+            Error::CommitmentCount(n, expected) => write!(
+                f,
+                "The proof has {} commitments, {} are expected",
+                n, expected
+            ),
+            Error::DeadlineExceeded => write!(f, "The deadline of the request passed"),
+            Error::EmptyList => write!(f, "The bid list is empty"),
             Error::Io(e) => write!(f, "{}", e),
+            Error::ListLenMismatch(t_c, n) => write!(
+                f,
+                "The proof has {} t_c entries for a bid list of {}",
+                t_c, n
+            ),
+            Error::ListTooLarge(n) => write!(
+                f,
+                "The bid list has {} entries, the maximum is {}",
+                n, MAX_LIST_LEN
+            ),
+            Error::NonCanonicalScalar => write!(f, "The scalar is not canonically encoded"),
+            Error::NotReady => write!(f, "The parameters are still loading"),
             Error::Other(s) => write!(f, "{}", s),
+            Error::ParamSetMismatch => write!(f, "The proof was made with another parameter set"),
             Error::R1CS(e) => write!(f, "{}", e),
+            Error::ShuttingDown => write!(f, "The server is shutting down"),
             Error::Tlv(e) => write!(f, "{}", e),
+            Error::ToggleOutOfRange(toggle, n) => write!(
+                f,
+                "The toggle {} is out of a bid list of {} entries",
+                toggle, n
+            ),
+            Error::TreeTooDeep(d) => write!(
+                f,
+                "The bid tree has {} levels, the maximum is {}",
+                d, MAX_TREE_DEPTH
+            ),
             Error::UnexpectedEof => write!(f, "Unexpected end of file"),
+            Error::UnknownJob(id) => write!(f, "The job {} does not exist or expired", id),
+            Error::UnknownOperation(op) => write!(f, "The operation code {} is undefined", op),
+            Error::UnknownProofVersion(v) => write!(f, "The proof version {} is not supported", v),
         }
     }
 }
@@ -0,0 +1,35 @@
+use crate::{Cancel, Error, Verify};
+
+use std::io::Read;
+
+use dusk_tlv::TlvReader;
+
+/// Verify a list of verify requests, returning the result of each of them.
+///
+/// A request that cannot be parsed is reported as invalid without failing the others.
+pub fn verify_batch<R: Read>(reader: R, cancel: &Cancel) -> Result<Vec<bool>, Error> {
+    let requests: Vec<Vec<u8>> = TlvReader::new(reader).read_list()?;
+
+    let parsed: Vec<Option<Verify>> = requests
+        .iter()
+        .map(|r| {
+            Verify::try_from_reader_variables(r.as_slice())
+                .map_err(|e| warn!("Discarding a malformed batch entry: {}", e))
+                .ok()
+        })
+        .collect();
+
+    let batch: Vec<Verify> = parsed.iter().flatten().cloned().collect();
+    cancel.check()?;
+
+    let mut results = match Verify::verify_batch(batch.as_slice()) {
+        Ok(()) => vec![true; batch.len()],
+        Err(results) => results.iter().map(|r| r.is_ok()).collect(),
+    }
+    .into_iter();
+
+    Ok(parsed
+        .iter()
+        .map(|v| v.is_some() && results.next().unwrap_or(false))
+        .collect())
+}
@@ -0,0 +1,236 @@
+use super::batch::verify_batch;
+use super::hello::hello;
+use super::jobs::{self, JobStore};
+use crate::blindbid::params_loaded;
+use crate::{Cancel, Error, Proof, Verify, WorkerPool};
+
+use std::convert::TryInto;
+use std::io::Write;
+use std::panic::{self, AssertUnwindSafe};
+use std::sync::Arc;
+use std::time::Instant;
+
+use bulletproofs::r1cs::R1CSError;
+use dusk_tlv::TlvWriter;
+
+/// Resolve a request, returning the result of its operation.
+///
+/// The request is abandoned with the error of `cancel` between the phases of its operation.
+pub fn dispatch(request: &[u8], cancel: &Cancel) -> Result<Vec<u8>, Error> {
+    cancel.check()?;
+    let (opcode, payload) = request.split_first().ok_or(Error::io_unexpected_eof(
+        "The operation code was not provided",
+    ))?;
+
+    // Proof
+    if *opcode == 1 {
+        prove(payload, cancel)
+    // Verify
+    } else if *opcode == 2 {
+        let verify = Verify::try_from_reader_variables(payload)
+            .and_then(|v| cancel.check().and_then(|_| v.verify()));
+
+        match verify {
+            Ok(()) => Ok(vec![0x01]),
+            Err(Error::R1CS(R1CSError::VerificationError)) => Ok(vec![0x00]),
+            Err(e) => Err(e),
+        }
+    // Batch verify
+    } else if *opcode == 3 {
+        verify_batch(payload, cancel).map(|results| {
+            // The overall result, followed by the result of every proof
+            let mut response = vec![results.iter().all(|&r| r) as u8];
+            response.extend(results.iter().map(|&r| r as u8));
+            response
+        })
+    // Undefined operation
+    } else {
+        Err(Error::UnknownOperation(*opcode))
+    }
+}
+
+/// Prove the request variables, returning the frame of the encoded proof followed by the frames
+/// of the blindings it was made with.
+pub fn prove(payload: &[u8], cancel: &Cancel) -> Result<Vec<u8>, Error> {
+    let (proof, blindings) = Proof::try_from_reader_cancellable(payload, cancel)?;
+    let proof: Vec<u8> = proof.try_into()?;
+
+    let mut response = TlvWriter::new(vec![]);
+    response.write(proof.as_slice())?;
+    for blinding in blindings.to_frames() {
+        response.write(blinding.as_slice())?;
+    }
+
+    Ok(response.into_inner())
+}
+
+/// Resolve the request if its operation is cheap enough to skip the worker pool, returning
+/// `None` otherwise.
+pub fn dispatch_now(
+    request: &[u8],
+    deadline: Option<Instant>,
+    pool: &WorkerPool,
+    jobs: &Arc<JobStore>,
+) -> Option<Result<Vec<u8>, Error>> {
+    match request.first() {
+        // Job operations
+        Some(4..=6) => jobs::dispatch(request, deadline, pool, jobs),
+        // Capabilities
+        Some(7) => Some(hello()),
+        // Health check
+        Some(8) => Some(ping()),
+        _ => None,
+    }
+}
+
+/// Succeed once the parameters are loaded.
+fn ping() -> Result<Vec<u8>, Error> {
+    if params_loaded() {
+        Ok(vec![])
+    } else {
+        Err(Error::NotReady)
+    }
+}
+
+/// Run the resolution of a request, turning a panic into an error so it only fails that
+/// request.
+pub fn catch_panic<T, F: FnOnce() -> Result<T, Error>>(f: F) -> Result<T, Error> {
+    panic::catch_unwind(AssertUnwindSafe(f)).unwrap_or_else(|cause| {
+        let cause = cause
+            .downcast_ref::<&str>()
+            .map(|s| s.to_string())
+            .or_else(|| cause.downcast_ref::<String>().cloned())
+            .unwrap_or_default();
+
+        Err(Error::Other(format!(
+            "The request failed unexpectedly: {}",
+            cause
+        )))
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::{BidWitness, Blindings};
+
+    use curve25519_dalek::ristretto::CompressedRistretto;
+    use curve25519_dalek::scalar::Scalar;
+    use dusk_tlv::TlvReader;
+    use serde::Serialize;
+
+    /// Prove request of a bid list of one bid, with the frames of `trailing` after the context
+    fn prove_request(d: Scalar, k: Scalar, seed: Scalar, trailing: &[Vec<u8>]) -> Vec<u8> {
+        let w = BidWitness::derive(d, k, seed);
+
+        let mut request = TlvWriter::new(vec![]);
+        for s in &[d, k, w.y, w.y_inv, w.q, w.z, seed] {
+            request.write(s.as_bytes()).unwrap();
+        }
+        request.write_list(&[w.x.as_bytes().to_vec()]).unwrap();
+        0u64.serialize(&mut request).unwrap();
+        request.write(b"round 7").unwrap();
+        for frame in trailing {
+            request.write(frame.as_slice()).unwrap();
+        }
+
+        request.into_inner()
+    }
+
+    #[test]
+    fn malformed_requests_are_errors() {
+        let cancel = Cancel::default();
+
+        assert!(dispatch(&[], &cancel).is_err());
+        for opcode in 1..=3 {
+            assert!(dispatch(&[opcode], &cancel).is_err());
+            assert!(dispatch(&[opcode, 0x00, 0x01], &cancel).is_err());
+        }
+
+        // An undefined operation is the fault of the client, not of the daemon
+        for &opcode in &[0x00, 0x09, 0xff] {
+            match dispatch(&[opcode], &cancel) {
+                Err(e @ Error::UnknownOperation(_)) => assert_eq!(0x15, e.code()),
+                r => panic!("Unexpected result of the operation {}: {:?}", opcode, r),
+            }
+        }
+    }
+
+    #[test]
+    fn prove_trailing_frames() {
+        let (d, k, seed) = (
+            Scalar::from(20000u64),
+            Scalar::from(7u64),
+            Scalar::from(9u64),
+        );
+
+        // Neither the message and one blinding, nor an incomplete set of blindings
+        for n in &[2, 5] {
+            let request = prove_request(d, k, seed, &vec![vec![0x01; 32]; *n]);
+            assert!(prove(request.as_slice(), &Cancel::default()).is_err());
+        }
+    }
+
+    #[test]
+    #[ignore]
+    fn requests_carry_the_d_commitment() {
+        let cancel = Cancel::default();
+        let (d, k, seed) = (
+            Scalar::from(20000u64),
+            Scalar::from(7u64),
+            Scalar::from(9u64),
+        );
+        let w = BidWitness::derive(d, k, seed);
+
+        for message in &[Some(b"block hash".to_vec()), None] {
+            let locked = Blindings::random();
+
+            let mut trailing: Vec<Vec<u8>> = message.iter().cloned().collect();
+            trailing.extend(locked.to_frames());
+            let response = prove(prove_request(d, k, seed, &trailing).as_slice(), &cancel).unwrap();
+
+            // The proof is made with the blindings of the request, and returns them
+            let mut response = TlvReader::new(response.as_slice());
+            let proof = response.next().unwrap().unwrap();
+            let blindings: Vec<Vec<u8>> =
+                (0..3).map(|_| response.next().unwrap().unwrap()).collect();
+            assert!(response.next().is_none());
+            assert_eq!(
+                locked,
+                Blindings::try_from_frames(blindings.as_slice()).unwrap()
+            );
+
+            let verify = |d_commitment: CompressedRistretto| {
+                let mut request = TlvWriter::new(vec![0x02]);
+                request.write(proof.as_slice()).unwrap();
+                for s in &[w.q, w.z, seed] {
+                    request.write(s.as_bytes()).unwrap();
+                }
+                request.write_list(&[w.x.as_bytes().to_vec()]).unwrap();
+                request.write(b"round 7").unwrap();
+                request.write(&[message.is_some() as u8]).unwrap();
+                request
+                    .write(message.as_ref().map(Vec::as_slice).unwrap_or_default())
+                    .unwrap();
+                request.write(d_commitment.as_bytes()).unwrap();
+
+                dispatch(request.into_inner().as_slice(), &cancel).unwrap()
+            };
+
+            assert_eq!(vec![0x01], verify(locked.d_commitment(d)));
+            assert_eq!(vec![0x00], verify(Blindings::random().d_commitment(d)));
+            assert_eq!(vec![0x00], verify(locked.d_commitment(d + Scalar::one())));
+        }
+    }
+
+    #[test]
+    fn panics_are_errors() {
+        let result: Result<(), Error> = catch_panic(|| panic!("malformed request"));
+        match result {
+            Err(Error::Other(s)) => assert!(s.ends_with("malformed request")),
+            r => panic!("Unexpected result of a panic: {:?}", r),
+        }
+
+        assert_eq!(Some(3), catch_panic(|| Ok(Some(3))).unwrap());
+    }
+}
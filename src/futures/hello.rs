@@ -0,0 +1,62 @@
+use crate::blindbid::{param_set_id, MAX_LIST_LEN};
+use crate::gadgets::MIMC_ROUNDS;
+use crate::Error;
+
+use std::io::Write;
+
+use dusk_tlv::TlvWriter;
+use serde::Serialize;
+
+/// Version of the framing and of the encoding of the operations, bumped on every incompatible
+/// change
+pub const PROTOCOL_VERSION: u64 = 4;
+
+/// Operation codes answered by this version of the daemon
+const OPCODES: [u8; 8] = [1, 2, 3, 4, 5, 6, 7, 8];
+
+/// Capabilities of the daemon: the protocol version, the crate version, the supported operation
+/// codes, the longest bid list, the MiMC rounds and the parameter set ID, each in its own frame.
+pub fn hello() -> Result<Vec<u8>, Error> {
+    let mut writer = TlvWriter::new(vec![]);
+
+    PROTOCOL_VERSION.serialize(&mut writer)?;
+    writer.write(env!("CARGO_PKG_VERSION").as_bytes())?;
+    writer.write(&OPCODES)?;
+    (MAX_LIST_LEN as u64).serialize(&mut writer)?;
+    (MIMC_ROUNDS as u64).serialize(&mut writer)?;
+    writer.write(&param_set_id())?;
+
+    Ok(writer.into_inner())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    use dusk_tlv::TlvReader;
+    use serde::Deserialize;
+
+    #[test]
+    fn hello_frames() {
+        let hello = hello().unwrap();
+        let mut reader = TlvReader::new(hello.as_slice());
+
+        let version: u64 = Deserialize::deserialize(&mut reader).unwrap();
+        assert_eq!(PROTOCOL_VERSION, version);
+
+        let crate_version = reader.next().unwrap().unwrap();
+        assert_eq!(
+            env!("CARGO_PKG_VERSION").as_bytes(),
+            crate_version.as_slice()
+        );
+        assert_eq!(OPCODES.to_vec(), reader.next().unwrap().unwrap());
+
+        let max_list_len: u64 = Deserialize::deserialize(&mut reader).unwrap();
+        let rounds: u64 = Deserialize::deserialize(&mut reader).unwrap();
+        assert_eq!(MAX_LIST_LEN as u64, max_list_len);
+        assert_eq!(MIMC_ROUNDS as u64, rounds);
+
+        assert_eq!(param_set_id().to_vec(), reader.next().unwrap().unwrap());
+        assert!(reader.next().is_none());
+    }
+}
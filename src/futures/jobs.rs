@@ -0,0 +1,341 @@
+use super::dispatch::{catch_panic, prove};
+use crate::{Cancel, Error, WorkerPool};
+
+use std::collections::HashMap;
+use std::sync::{Arc, Mutex, MutexGuard};
+use std::time::{Duration, Instant};
+
+use rand::{thread_rng, Rng};
+
+/// Default time the result of a job is kept after it is finished
+pub const DEFAULT_JOB_TTL: Duration = Duration::from_secs(300);
+/// Default number of jobs kept, finished or not, before the new ones are rejected as busy
+pub const DEFAULT_MAX_JOBS: usize = 1024;
+
+/// Job waiting for a worker
+pub const JOB_QUEUED: u8 = 0x00;
+/// Job being proved
+pub const JOB_RUNNING: u8 = 0x01;
+/// Job finished with a proof, as the response to a prove request
+pub const JOB_DONE: u8 = 0x02;
+/// Job finished with an error
+pub const JOB_FAILED: u8 = 0x03;
+/// Job cancelled by the client
+pub const JOB_CANCELLED: u8 = 0x04;
+
+enum JobState {
+    Queued,
+    Running,
+    Done(Vec<u8>),
+    Failed(u8, String),
+    Cancelled,
+}
+
+struct Job {
+    state: JobState,
+    cancel: Cancel,
+    finished: Option<Instant>,
+}
+
+/// Prove jobs submitted by the clients, resolved on the worker pool and collected later.
+///
+/// The IDs of the jobs are random, so a client cannot guess the jobs of the others.
+pub struct JobStore {
+    ttl: Duration,
+    max_jobs: usize,
+    jobs: Mutex<HashMap<u64, Job>>,
+}
+
+impl JobStore {
+    /// The results of the jobs are discarded `ttl` after they are finished, and up to
+    /// `max_jobs` jobs are kept meanwhile.
+    pub fn new(ttl: Duration, max_jobs: usize) -> Self {
+        JobStore {
+            ttl,
+            max_jobs,
+            jobs: Mutex::new(HashMap::new()),
+        }
+    }
+
+    /// Queue the prove request `payload` on `pool`, returning the ID of the job.
+    ///
+    /// The job fails with `Error::DeadlineExceeded` if it is not done by `deadline`. It is
+    /// rejected with `Error::Busy` while `max_jobs` jobs are kept.
+    pub fn submit(
+        self: Arc<Self>,
+        pool: &WorkerPool,
+        payload: Vec<u8>,
+        deadline: Option<Instant>,
+    ) -> Result<u64, Error> {
+        let cancel = Cancel::new(deadline);
+
+        let id = {
+            let mut jobs = self.lock()?;
+            if jobs.len() >= self.max_jobs {
+                return Err(Error::Busy);
+            }
+
+            let mut rng = thread_rng();
+            let mut id: u64 = rng.gen();
+            while jobs.contains_key(&id) {
+                id = rng.gen();
+            }
+
+            jobs.insert(
+                id,
+                Job {
+                    state: JobState::Queued,
+                    cancel: cancel.clone(),
+                    finished: None,
+                },
+            );
+
+            id
+        };
+
+        let store = Arc::clone(&self);
+        let queued = pool.execute(move || {
+            if store.start(id) {
+                let result = catch_panic(|| {
+                    cancel.check()?;
+                    prove(payload.as_slice(), &cancel)
+                });
+                store.finish(id, result);
+            }
+        });
+
+        if let Err(e) = queued {
+            self.lock()?.remove(&id);
+            return Err(e);
+        }
+
+        Ok(id)
+    }
+
+    /// State of the job `id`, followed by the proof once done, or by the status code and the
+    /// description of the error once failed.
+    pub fn status(&self, id: u64) -> Result<Vec<u8>, Error> {
+        let jobs = self.lock()?;
+        let job = jobs.get(&id).ok_or(Error::UnknownJob(id))?;
+
+        Ok(match &job.state {
+            JobState::Queued => vec![JOB_QUEUED],
+            JobState::Running => vec![JOB_RUNNING],
+            JobState::Done(proof) => {
+                let mut status = vec![JOB_DONE];
+                status.extend_from_slice(proof.as_slice());
+                status
+            }
+            JobState::Failed(code, description) => {
+                let mut status = vec![JOB_FAILED, *code];
+                status.extend_from_slice(description.as_bytes());
+                status
+            }
+            JobState::Cancelled => vec![JOB_CANCELLED],
+        })
+    }
+
+    /// Cancel the job `id`, returning its state afterwards.
+    ///
+    /// A running job is abandoned between the phases of the proof. A finished job keeps its
+    /// result.
+    pub fn cancel(&self, id: u64) -> Result<u8, Error> {
+        let mut jobs = self.lock()?;
+        let job = jobs.get_mut(&id).ok_or(Error::UnknownJob(id))?;
+
+        match job.state {
+            JobState::Queued | JobState::Running => {
+                job.cancel.cancel();
+                job.state = JobState::Cancelled;
+                job.finished = Some(Instant::now());
+                Ok(JOB_CANCELLED)
+            }
+            JobState::Done(_) => Ok(JOB_DONE),
+            JobState::Failed(_, _) => Ok(JOB_FAILED),
+            JobState::Cancelled => Ok(JOB_CANCELLED),
+        }
+    }
+
+    /// Mark a queued job as running, returning false if it was cancelled meanwhile.
+    fn start(&self, id: u64) -> bool {
+        let mut jobs = match self.lock() {
+            Ok(jobs) => jobs,
+            Err(_) => return false,
+        };
+
+        match jobs.get_mut(&id) {
+            Some(job) if job.is_queued() => {
+                job.state = JobState::Running;
+                true
+            }
+            _ => false,
+        }
+    }
+
+    fn finish(&self, id: u64, result: Result<Vec<u8>, Error>) {
+        let mut jobs = match self.lock() {
+            Ok(jobs) => jobs,
+            Err(_) => return,
+        };
+
+        if let Some(job) = jobs.get_mut(&id) {
+            if let JobState::Running = job.state {
+                job.state = match result {
+                    Ok(proof) => JobState::Done(proof),
+                    Err(e) => {
+                        error!("Error resolving the job {}: {}", id, e);
+                        JobState::Failed(e.code(), e.to_string())
+                    }
+                };
+                job.finished = Some(Instant::now());
+            }
+        }
+    }
+
+    /// Lock the jobs, discarding the expired ones.
+    fn lock(&self) -> Result<MutexGuard<HashMap<u64, Job>>, Error> {
+        let mut jobs = self
+            .jobs
+            .lock()
+            .map_err(|_| Error::Other("The job store is poisoned".to_owned()))?;
+
+        let ttl = self.ttl;
+        jobs.retain(|_, job| job.finished.map(|t| t.elapsed() < ttl).unwrap_or(true));
+
+        Ok(jobs)
+    }
+}
+
+impl Default for JobStore {
+    fn default() -> Self {
+        JobStore::new(DEFAULT_JOB_TTL, DEFAULT_MAX_JOBS)
+    }
+}
+
+impl Job {
+    fn is_queued(&self) -> bool {
+        match self.state {
+            JobState::Queued => true,
+            _ => false,
+        }
+    }
+}
+
+/// Resolve a job operation, returning `None` if the request is not one.
+pub fn dispatch(
+    request: &[u8],
+    deadline: Option<Instant>,
+    pool: &WorkerPool,
+    store: &Arc<JobStore>,
+) -> Option<Result<Vec<u8>, Error>> {
+    let (opcode, payload) = match request.split_first() {
+        Some((opcode, payload)) => (*opcode, payload),
+        None => return None,
+    };
+
+    // Submit a prove job
+    let result = if opcode == 4 {
+        Arc::clone(store)
+            .submit(pool, payload.to_vec(), deadline)
+            .map(|id| id.to_le_bytes().to_vec())
+    // Job status
+    } else if opcode == 5 {
+        job_id(payload).and_then(|id| store.status(id))
+    // Cancel a job
+    } else if opcode == 6 {
+        job_id(payload).and_then(|id| store.cancel(id).map(|state| vec![state]))
+    } else {
+        return None;
+    };
+
+    Some(result)
+}
+
+fn job_id(payload: &[u8]) -> Result<u64, Error> {
+    if payload.len() != 8 {
+        return Err(Error::io_invalid_data("The job ID must be 8 bytes"));
+    }
+
+    let mut id = [0x00u8; 8];
+    id.copy_from_slice(payload);
+
+    Ok(u64::from_le_bytes(id))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    use std::sync::mpsc::channel;
+    use std::thread;
+
+    #[test]
+    fn job_lifecycle() {
+        let pool = WorkerPool::new(1, 4).unwrap();
+        let store = Arc::new(JobStore::new(Duration::from_millis(200), DEFAULT_MAX_JOBS));
+
+        // Keep the only worker busy, so the next jobs stay queued
+        let (release, wait_release) = channel::<()>();
+        pool.execute(move || wait_release.recv().unwrap()).unwrap();
+
+        let failing = Arc::clone(&store).submit(&pool, vec![0xff], None).unwrap();
+        let cancelled = Arc::clone(&store).submit(&pool, vec![], None).unwrap();
+        let late = Arc::clone(&store)
+            .submit(&pool, vec![], Some(Instant::now()))
+            .unwrap();
+        assert_eq!(vec![JOB_QUEUED], store.status(failing).unwrap());
+
+        assert_eq!(JOB_CANCELLED, store.cancel(cancelled).unwrap());
+        release.send(()).unwrap();
+
+        // A malformed request fails as soon as it is picked
+        while store.status(failing).unwrap()[0] < JOB_DONE {
+            thread::sleep(Duration::from_millis(10));
+        }
+        assert_eq!(JOB_FAILED, store.status(failing).unwrap()[0]);
+        assert_eq!(JOB_FAILED, store.cancel(failing).unwrap());
+        assert_eq!(vec![JOB_CANCELLED], store.status(cancelled).unwrap());
+
+        // The deadline passed while the job was queued
+        while store.status(late).unwrap()[0] < JOB_DONE {
+            thread::sleep(Duration::from_millis(10));
+        }
+        assert_eq!(
+            vec![JOB_FAILED, Error::DeadlineExceeded.code()],
+            store.status(late).unwrap()[..2].to_vec()
+        );
+
+        thread::sleep(Duration::from_millis(300));
+        match store.status(failing) {
+            Err(Error::UnknownJob(id)) => assert_eq!(failing, id),
+            _ => panic!("The expired job was kept"),
+        }
+    }
+
+    #[test]
+    fn jobs_are_capped() {
+        let pool = WorkerPool::new(1, 4).unwrap();
+        let store = Arc::new(JobStore::new(Duration::from_millis(200), 2));
+
+        let (release, wait_release) = channel::<()>();
+        pool.execute(move || wait_release.recv().unwrap()).unwrap();
+
+        let first = Arc::clone(&store).submit(&pool, vec![], None).unwrap();
+        let second = Arc::clone(&store).submit(&pool, vec![], None).unwrap();
+        assert_ne!(first, second);
+
+        // The finished jobs count until they expire
+        assert_eq!(JOB_CANCELLED, store.cancel(first).unwrap());
+        match Arc::clone(&store).submit(&pool, vec![], None) {
+            Err(Error::Busy) => (),
+            r => panic!("Unexpected result past the cap: {:?}", r),
+        }
+        release.send(()).unwrap();
+
+        while store.status(second).unwrap()[0] < JOB_DONE {
+            thread::sleep(Duration::from_millis(10));
+        }
+        thread::sleep(Duration::from_millis(300));
+        assert!(Arc::clone(&store).submit(&pool, vec![], None).is_ok());
+    }
+}
@@ -1,15 +1,18 @@
-use super::prove::ProveFuture;
-use super::verify::VerifyFuture;
-use crate::Error;
+use super::dispatch::{catch_panic, dispatch, dispatch_now};
+use super::jobs::JobStore;
+use crate::{Cancel, Error, WorkerPool, STATUS_OK};
 
-use std::convert::TryInto;
 use std::future::Future;
-use std::io::{self, Write};
+use std::io::{self, Read, Write};
 use std::os::unix::net::UnixStream;
 use std::pin::Pin;
+use std::sync::atomic::{AtomicBool, AtomicUsize, Ordering};
+use std::sync::{Arc, Mutex};
 use std::task::{Context, Poll};
+use std::thread;
+use std::time::{Duration, Instant};
 
-use dusk_tlv::{TlvReader, TlvWriter};
+use dusk_tlv::TlvWriter;
 use dusk_uds::{Message, TaskProvider};
 
 macro_rules! try_result_future {
@@ -24,34 +27,68 @@ macro_rules! try_result_future {
     };
 }
 
-macro_rules! try_poll {
-    ($e:expr, $c:expr) => {
-        match Pin::new(&mut $e).poll($c) {
-            Poll::Ready(a) => {
-                trace!("Request resolved");
-                a
-            }
-            Poll::Pending => {
-                trace!("Pending request discarded");
-                return Poll::Pending;
-            }
-        }
-    };
-}
+/// Default time a connection can stay idle between two requests
+pub const DEFAULT_IDLE_TIMEOUT: Duration = Duration::from_secs(30);
+/// Default number of connections served at once
+pub const DEFAULT_MAX_CONNECTIONS: usize = 256;
+/// Longest request frame read, far above any request
+const MAX_FRAME_LEN: u64 = 1 << 30;
 
 pub struct MainFuture {
     socket: Option<UnixStream>,
+    idle_timeout: Option<Duration>,
+    max_connections: usize,
+    connections: Arc<AtomicUsize>,
+    pool: Arc<WorkerPool>,
+    jobs: Arc<JobStore>,
+}
+
+impl MainFuture {
+    /// Connections are closed when no request arrives for `idle_timeout`; `None` keeps them
+    /// open until the client closes them. Up to `max_connections` connections are served at
+    /// once, and the ones beyond are answered with `Error::Busy` and closed.
+    ///
+    /// The requests of every connection are resolved by `pool`, and the prove jobs are kept in
+    /// `jobs`.
+    pub fn new(
+        idle_timeout: Option<Duration>,
+        max_connections: usize,
+        pool: Arc<WorkerPool>,
+        jobs: Arc<JobStore>,
+    ) -> Self {
+        MainFuture {
+            socket: None,
+            idle_timeout,
+            max_connections,
+            connections: Arc::new(AtomicUsize::new(0)),
+            pool,
+            jobs,
+        }
+    }
 }
 
 impl Default for MainFuture {
     fn default() -> Self {
-        MainFuture { socket: None }
+        MainFuture::new(
+            Some(DEFAULT_IDLE_TIMEOUT),
+            DEFAULT_MAX_CONNECTIONS,
+            Arc::new(WorkerPool::default()),
+            Arc::new(JobStore::default()),
+        )
     }
 }
 
 impl Clone for MainFuture {
+    /// The clones count the same connections.
     fn clone(&self) -> Self {
-        MainFuture::default()
+        MainFuture {
+            socket: None,
+            idle_timeout: self.idle_timeout,
+            max_connections: self.max_connections,
+            connections: Arc::clone(&self.connections),
+            pool: Arc::clone(&self.pool),
+            jobs: Arc::clone(&self.jobs),
+        }
     }
 }
 
@@ -64,48 +101,294 @@ impl TaskProvider for MainFuture {
 impl Future for MainFuture {
     type Output = Message;
 
-    fn poll(mut self: Pin<&mut Self>, cx: &mut Context) -> Poll<Self::Output> {
-        match &mut self.socket {
+    /// Hand the connection over to its own thread, so a client keeping it open does not hold
+    /// up the executor accepting the other connections.
+    fn poll(mut self: Pin<&mut Self>, _cx: &mut Context) -> Poll<Self::Output> {
+        let idle_timeout = self.idle_timeout;
+        let pool = Arc::clone(&self.pool);
+        let store = Arc::clone(&self.jobs);
+
+        match self.socket.take() {
             Some(s) => {
-                let mut reader = TlvReader::new(s);
-                // Fetch the full request
-                let request = reader.next().transpose();
-                let request = try_result_future!(request);
-                let request = request.ok_or(Error::Io(io::Error::new(
-                    io::ErrorKind::UnexpectedEof,
-                    "The request was not provided",
-                )));
-                let request = try_result_future!(request);
-                let s = reader.into_inner();
-
-                let opcode = request[0];
-
-                // Proof
-                if opcode == 1 {
-                    let proof = try_poll!(ProveFuture::new(&request[1..]), cx);
-                    let proof = try_result_future!(proof);
-                    let proof: Vec<u8> = try_result_future!(proof.try_into());
-
-                    let mut writer = TlvWriter::new(s);
-                    try_result_future!(writer.write(proof.as_slice()));
-
-                    Poll::Ready(Message::Success)
-                // Verify
-                } else if opcode == 2 {
-                    let verify = try_poll!(VerifyFuture::new(&request[1..]), cx).is_ok();
-                    let verify = if verify { 0x01u8 } else { 0x00u8 };
-
-                    let mut writer = TlvWriter::new(s);
-                    try_result_future!(writer.write(&[verify]));
-
-                    Poll::Ready(Message::Success)
-                // Undefined operation
-                } else {
-                    try_result_future!(Err(Error::Other("Undefined operation code".to_owned())))
-                }
+                let slot = match ConnectionSlot::acquire(&self.connections, self.max_connections) {
+                    Some(slot) => slot,
+                    None => {
+                        warn!("Refusing a connection, {} are open", self.max_connections);
+                        let writer = Mutex::new(s);
+                        try_result_future!(respond(&writer, 0, Err(Error::Busy)));
+
+                        return Poll::Ready(Message::Success);
+                    }
+                };
+
+                let connection = move || {
+                    if let Err(e) = serve(s, idle_timeout, &pool, &store) {
+                        error!("Error serving a connection: {}", e);
+                    }
+
+                    drop(slot);
+                };
+
+                let builder = thread::Builder::new().name("connection".to_owned());
+                try_result_future!(builder.spawn(connection));
+
+                Poll::Ready(Message::Success)
             }
 
             None => try_result_future!(Err(Error::Other("No socket provided".to_owned()))),
         }
     }
 }
+
+/// Read the requests of a connection until the client closes it, or until it stays idle for
+/// `idle_timeout`.
+fn serve(
+    mut s: UnixStream,
+    idle_timeout: Option<Duration>,
+    pool: &WorkerPool,
+    store: &Arc<JobStore>,
+) -> Result<(), Error> {
+    s.set_read_timeout(idle_timeout)?;
+
+    // The responses are written by the threads resolving the requests
+    let writer = Arc::new(Mutex::new(s.try_clone()?));
+    let in_flight = Arc::new(AtomicUsize::new(0));
+    let closed = Closed(Arc::new(AtomicBool::new(false)));
+
+    loop {
+        let mut first = [0x00u8; 1];
+        match s.read(&mut first) {
+            Ok(0) => {
+                trace!("Connection closed by the client");
+                return Ok(());
+            }
+            Ok(_) => (),
+            Err(ref e)
+                if e.kind() == io::ErrorKind::WouldBlock || e.kind() == io::ErrorKind::TimedOut =>
+            {
+                // The client is still waiting for some responses
+                if in_flight.load(Ordering::SeqCst) > 0 {
+                    continue;
+                }
+
+                debug!("Closing an idle connection");
+                return Ok(());
+            }
+            Err(e) => return Err(e.into()),
+        }
+
+        // Fetch the full request
+        let request = read_frame(first[0], &mut s);
+
+        // A malformed frame leaves the stream out of sync, so the connection is closed after
+        // the error is reported
+        let (id, deadline, request) = match request.and_then(|r| split_header(&r)) {
+            Ok(request) => request,
+            Err(e) => {
+                error!("Error resolving the request: {}", e);
+                return respond(&writer, 0, Err(e));
+            }
+        };
+
+        // The cheap operations are answered right away
+        let result = catch_panic(|| Ok(dispatch_now(request.as_slice(), deadline, pool, store)));
+        if let Some(result) = result.transpose() {
+            if let Err(e) = &result {
+                error!("Error resolving the request {}: {}", id, e);
+            }
+
+            respond(&writer, id, result)?;
+            continue;
+        }
+
+        // Resolve the request on the worker pool, answering as soon as it is done
+        in_flight.fetch_add(1, Ordering::SeqCst);
+
+        // The request is abandoned if the client goes away before it is resolved
+        let cancel = Cancel::with_flag(deadline, Arc::clone(&closed.0));
+
+        let (job_writer, job_in_flight) = (Arc::clone(&writer), Arc::clone(&in_flight));
+        let queued = pool.execute(move || {
+            match catch_panic(|| dispatch(request.as_slice(), &cancel)) {
+                Err(Error::Cancelled) => {
+                    debug!("Request {} abandoned, the client is gone", id);
+                }
+                result => {
+                    if let Err(e) = &result {
+                        error!("Error resolving the request {}: {}", id, e);
+                    }
+
+                    if let Err(e) = respond(&job_writer, id, result) {
+                        error!("Error answering the request {}: {}", id, e);
+                    }
+                }
+            }
+
+            job_in_flight.fetch_sub(1, Ordering::SeqCst);
+        });
+
+        // A full queue is reported right away, the client can retry later
+        if let Err(e) = queued {
+            warn!("Rejecting the request {}: {}", id, e);
+            in_flight.fetch_sub(1, Ordering::SeqCst);
+            respond(&writer, id, Err(e))?;
+        }
+    }
+}
+
+/// Connection counted against the maximum until it is dropped.
+struct ConnectionSlot(Arc<AtomicUsize>);
+
+impl ConnectionSlot {
+    /// Count a new connection, unless `max` are already open.
+    fn acquire(connections: &Arc<AtomicUsize>, max: usize) -> Option<Self> {
+        if connections.fetch_add(1, Ordering::SeqCst) >= max {
+            connections.fetch_sub(1, Ordering::SeqCst);
+            return None;
+        }
+
+        Some(ConnectionSlot(Arc::clone(connections)))
+    }
+}
+
+impl Drop for ConnectionSlot {
+    fn drop(&mut self) {
+        self.0.fetch_sub(1, Ordering::SeqCst);
+    }
+}
+
+/// Flag cancelling the pending requests of a connection once it is closed.
+struct Closed(Arc<AtomicBool>);
+
+impl Drop for Closed {
+    fn drop(&mut self) {
+        self.0.store(true, Ordering::SeqCst);
+    }
+}
+
+/// Read the value of a frame whose type byte, the size of its length, is `length_size`.
+///
+/// A length above `MAX_FRAME_LEN` is rejected, and the value grows with the data actually read
+/// instead of trusting the length.
+fn read_frame<R: Read>(length_size: u8, reader: &mut R) -> Result<Vec<u8>, Error> {
+    let mut length = [0x00u8; 8];
+    match length_size {
+        1 | 2 | 4 | 8 => reader.read_exact(&mut length[..length_size as usize])?,
+        t => {
+            return Err(Error::io_invalid_data(format!(
+                "The frame length cannot be {} bytes",
+                t
+            )))
+        }
+    }
+
+    let length = u64::from_le_bytes(length);
+    if length > MAX_FRAME_LEN {
+        return Err(Error::io_invalid_data(format!(
+            "The frame of {} bytes is longer than {}",
+            length, MAX_FRAME_LEN
+        )));
+    }
+
+    let mut value = vec![];
+    reader.take(length).read_to_end(&mut value)?;
+    if value.len() as u64 != length {
+        return Err(Error::io_unexpected_eof("The request frame is truncated"));
+    }
+
+    Ok(value)
+}
+
+/// Split a request frame into its request ID, its deadline and the request.
+///
+/// The deadline is sent as a timeout in milliseconds from the arrival of the request, 0 being
+/// no deadline.
+fn split_header(frame: &[u8]) -> Result<(u64, Option<Instant>, Vec<u8>), Error> {
+    if frame.len() < 16 {
+        return Err(Error::io_unexpected_eof(
+            "The request ID and timeout were not provided",
+        ));
+    }
+
+    let mut id = [0x00u8; 8];
+    id.copy_from_slice(&frame[..8]);
+
+    let mut timeout = [0x00u8; 8];
+    timeout.copy_from_slice(&frame[8..16]);
+    let deadline = match u64::from_le_bytes(timeout) {
+        0 => None,
+        t => Instant::now().checked_add(Duration::from_millis(t)),
+    };
+
+    Ok((u64::from_le_bytes(id), deadline, frame[16..].to_vec()))
+}
+
+/// Write the response frame of the request `id`.
+fn respond(
+    writer: &Mutex<UnixStream>,
+    id: u64,
+    result: Result<Vec<u8>, Error>,
+) -> Result<(), Error> {
+    let response = match result {
+        Ok(body) => envelope(id, STATUS_OK, body.as_slice()),
+        Err(e) => envelope(id, e.code(), e.to_string().as_bytes()),
+    };
+
+    let mut stream = writer
+        .lock()
+        .map_err(|_| Error::Other("The connection writer is poisoned".to_owned()))?;
+
+    TlvWriter::new(&mut *stream).write(response.as_slice())?;
+
+    Ok(())
+}
+
+/// Response envelope: the request ID and the status code, followed by the result or by the
+/// error description.
+fn envelope(id: u64, status: u8, body: &[u8]) -> Vec<u8> {
+    let mut response = Vec::with_capacity(body.len() + 9);
+
+    response.extend_from_slice(&id.to_le_bytes());
+    response.push(status);
+    response.extend_from_slice(body);
+
+    response
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn frames_are_capped() {
+        let read = |bytes: &[u8]| read_frame(bytes[0], &mut &bytes[1..]);
+
+        assert_eq!(b"dbbp".to_vec(), read(b"\x01\x04dbbp").unwrap());
+        assert_eq!(b"d".to_vec(), read(b"\x02\x01\x00d").unwrap());
+
+        // Unknown length size, truncated value
+        assert!(read(b"\x03\x01\x00\x00d").is_err());
+        assert!(read(b"\x01\x04dbb").is_err());
+
+        // Neither 2^63 nor anything above the maximum is allocated
+        assert!(read(b"\x08\x00\x00\x00\x00\x00\x00\x00\x80d").is_err());
+        assert!(read(b"\x04\x01\x00\x00\x40d").is_err());
+    }
+
+    #[test]
+    fn connections_are_capped() {
+        let connections = Arc::new(AtomicUsize::new(0));
+
+        let first = ConnectionSlot::acquire(&connections, 2).unwrap();
+        let second = ConnectionSlot::acquire(&connections, 2).unwrap();
+        assert!(ConnectionSlot::acquire(&connections, 2).is_none());
+        assert_eq!(2, connections.load(Ordering::SeqCst));
+
+        // A closed connection frees its slot
+        drop(first);
+        assert!(ConnectionSlot::acquire(&connections, 2).is_some());
+
+        drop(second);
+        assert_eq!(0, connections.load(Ordering::SeqCst));
+    }
+}
@@ -1,5 +1,9 @@
-pub use main::MainFuture;
+pub use hello::PROTOCOL_VERSION;
+pub use jobs::{JobStore, DEFAULT_JOB_TTL, DEFAULT_MAX_JOBS};
+pub use main::{MainFuture, DEFAULT_IDLE_TIMEOUT, DEFAULT_MAX_CONNECTIONS};
 
+mod batch;
+mod dispatch;
+mod hello;
+mod jobs;
 mod main;
-mod prove;
-mod verify;
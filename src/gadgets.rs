@@ -1,4 +1,4 @@
-use bulletproofs::r1cs::{ConstraintSystem, LinearCombination, Variable};
+use bulletproofs::r1cs::{ConstraintSystem, LinearCombination, R1CSError, Variable};
 use curve25519_dalek::scalar::Scalar;
 
 pub const MIMC_ROUNDS: usize = 90;
@@ -14,14 +14,14 @@ pub fn proof_gadget<CS: ConstraintSystem>(
     constants: &Vec<Scalar>,
     toggle: Vec<Variable>, // private: binary list indicating private number is somewhere in list
     items: Vec<LinearCombination>, // public list
-) {
+) -> Result<(), R1CSError> {
     assert_eq!(MIMC_ROUNDS, constants.len());
     // Prove z
     let m = mimc_gadget(cs, k, Scalar::zero().into(), &constants);
 
     let x = mimc_gadget(cs, d.clone(), m.clone(), &constants);
 
-    one_of_many_gadget(cs, x.clone(), toggle, items);
+    one_of_many_gadget(cs, x.clone(), toggle, items)?;
 
     let y = mimc_gadget(cs, seed.clone(), x, &constants);
 
@@ -31,6 +31,8 @@ pub fn proof_gadget<CS: ConstraintSystem>(
 
     // Prove Q
     score_gadget(cs, d, y, y_inv, q);
+
+    Ok(())
 }
 
 // N.B. the constrain on the image has been removed, as we will not know the intermediate images
@@ -90,8 +92,17 @@ fn one_of_many_gadget<CS: ConstraintSystem>(
     x: LinearCombination,          // private: our item x
     toggle: Vec<Variable>,         // private: binary list indicating it is somewhere in list
     items: Vec<LinearCombination>, // public list
-) {
+) -> Result<(), R1CSError> {
     let toggle_len = toggle.len();
+    if toggle_len == 0 || toggle_len != items.len() {
+        return Err(R1CSError::GadgetError {
+            description: format!(
+                "{} toggles provided for a list of {} items",
+                toggle_len,
+                items.len()
+            ),
+        });
+    }
 
     // ensure every item in toggle is binary
     for i in toggle.iter() {
@@ -129,6 +140,8 @@ fn one_of_many_gadget<CS: ConstraintSystem>(
         let (_, _, right) = cs.multiply(toggle[i].clone().into(), x.clone());
         cs.constrain(left - right);
     }
+
+    Ok(())
 }
 
 fn boolean_gadget<CS: ConstraintSystem>(cs: &mut CS, a1: LinearCombination) {
@@ -138,3 +151,102 @@ fn boolean_gadget<CS: ConstraintSystem>(cs: &mut CS, a1: LinearCombination) {
     let (_, _, c_var) = cs.multiply(a, one - a1);
     cs.constrain(c_var.into());
 }
+
+/// Same as `proof_gadget`, with the membership of x proven by a MiMC Merkle tree.
+///
+/// `path` holds, from the leaf up, the position bit of the node (1 if it is the right child)
+/// and its sibling. The verifier provides `None` for each level.
+pub fn tree_proof_gadget<CS: ConstraintSystem>(
+    cs: &mut CS,
+    d: LinearCombination,
+    k: LinearCombination,
+    y_inv: LinearCombination,
+    q: LinearCombination,
+    z_img: LinearCombination,
+    seed: LinearCombination,
+    constants: &Vec<Scalar>,
+    path: Vec<Option<(Scalar, Scalar)>>, // private: authentication path of x
+    root: LinearCombination,             // public: root of the bid tree
+) -> Result<(), R1CSError> {
+    assert_eq!(MIMC_ROUNDS, constants.len());
+    // Prove z
+    let m = mimc_gadget(cs, k, Scalar::zero().into(), &constants);
+
+    let x = mimc_gadget(cs, d.clone(), m.clone(), &constants);
+
+    merkle_gadget(cs, x.clone(), path, root, &constants)?;
+
+    let y = mimc_gadget(cs, seed.clone(), x, &constants);
+
+    let z = mimc_gadget(cs, seed, m, &constants);
+
+    cs.constrain(z_img - z);
+
+    // Prove Q
+    score_gadget(cs, d, y, y_inv, q);
+
+    Ok(())
+}
+
+fn merkle_gadget<CS: ConstraintSystem>(
+    cs: &mut CS,
+    leaf: LinearCombination,
+    path: Vec<Option<(Scalar, Scalar)>>,
+    root: LinearCombination,
+    constants: &Vec<Scalar>,
+) -> Result<(), R1CSError> {
+    let one = Scalar::one();
+    let mut node = leaf;
+
+    for level in path {
+        // bit * (1 - bit) = 0
+        let (bit, bit_neg, c_var) =
+            cs.allocate_multiplier(level.map(|(bit, _)| (bit, one - bit)))?;
+        cs.constrain(c_var.into());
+        cs.constrain(bit + bit_neg - one);
+
+        let sibling: LinearCombination = cs.allocate(level.map(|(_, sibling)| sibling))?.into();
+
+        // Swap node and sibling when the node is the right child
+        let (_, _, t) = cs.multiply(bit.into(), sibling.clone() - node.clone());
+        let left = node + t;
+        let right = sibling - t;
+
+        node = mimc_gadget(cs, left, right, &constants);
+    }
+
+    cs.constrain(root - node);
+
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    use bulletproofs::r1cs::Prover;
+    use bulletproofs::PedersenGens;
+    use merlin::Transcript;
+
+    #[test]
+    fn one_of_many_rejects_mismatched_lists() {
+        let pc_gens = PedersenGens::default();
+        let mut transcript = Transcript::new(b"one_of_many");
+        let mut prover = Prover::new(&pc_gens, &mut transcript);
+
+        let (_, toggle): (Vec<_>, Vec<Variable>) = (0..3u64)
+            .map(|i| prover.commit(Scalar::from((i == 0) as u8), Scalar::zero()))
+            .unzip();
+        let x: LinearCombination = Scalar::one().into();
+
+        // Fewer items than toggles
+        let items = vec![Scalar::one().into(); 2];
+        assert!(one_of_many_gadget(&mut prover, x.clone(), toggle.clone(), items).is_err());
+
+        // Empty list
+        assert!(one_of_many_gadget(&mut prover, x.clone(), vec![], vec![]).is_err());
+
+        let items = vec![Scalar::one().into(); 3];
+        assert!(one_of_many_gadget(&mut prover, x, toggle, items).is_ok());
+    }
+}
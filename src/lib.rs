@@ -3,11 +3,21 @@ extern crate lazy_static;
 #[macro_use]
 extern crate log;
 
-pub use blindbid::{Bid, Proof, Verify};
-pub use error::Error;
-pub use futures::MainFuture;
+pub use blindbid::{
+    mimc_hash, Bid, BidTree, BidWitness, Blindings, MerklePath, Proof, ProofVersion, Verify,
+    VerifyTree,
+};
+pub use cancel::Cancel;
+pub use error::{Error, STATUS_OK};
+pub use futures::{
+    JobStore, MainFuture, DEFAULT_IDLE_TIMEOUT, DEFAULT_JOB_TTL, DEFAULT_MAX_CONNECTIONS,
+    DEFAULT_MAX_JOBS, PROTOCOL_VERSION,
+};
+pub use pool::{WorkerPool, DEFAULT_QUEUE_LEN};
 
 pub mod blindbid;
+mod cancel;
 mod error;
 mod futures;
 pub mod gadgets;
+mod pool;
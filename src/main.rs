@@ -1,19 +1,48 @@
 use std::env;
-use std::path::PathBuf;
+use std::fs;
+use std::io::{self, Write};
+use std::os::unix::fs::FileTypeExt;
+use std::os::unix::net::UnixStream;
+use std::path::{Path, PathBuf};
+use std::process;
+use std::sync::Arc;
+use std::thread;
+use std::time::Duration;
 
-use dusk_blindbidproof::MainFuture;
+use dusk_blindbidproof::blindbid;
+use dusk_blindbidproof::{Error, JobStore, MainFuture, WorkerPool, STATUS_OK};
+use dusk_blindbidproof::{
+    DEFAULT_IDLE_TIMEOUT, DEFAULT_JOB_TTL, DEFAULT_MAX_CONNECTIONS, DEFAULT_MAX_JOBS,
+    DEFAULT_QUEUE_LEN,
+};
 
 use clap::{App, Arg};
+use dusk_tlv::{TlvReader, TlvWriter};
 use dusk_uds::UnixDomainSocket;
+use log::{error, info, warn};
+use signal_hook::iterator::Signals;
+use signal_hook::{SIGINT, SIGTERM};
 
 const NAME: Option<&'static str> = option_env!("CARGO_PKG_NAME");
 const VERSION: Option<&'static str> = option_env!("CARGO_PKG_VERSION");
 const AUTHORS: Option<&'static str> = option_env!("CARGO_PKG_AUTHORS");
 
+/// Interval between the pings waiting for the daemon to be ready
+const READY_POLL: Duration = Duration::from_millis(50);
+/// Default time the running requests have to finish on shutdown
+const DEFAULT_SHUTDOWN_TIMEOUT: Duration = Duration::from_secs(30);
+
 fn main() {
     let mut uds = env::temp_dir();
     uds.push("dusk-uds-blindbid");
     let uds_default = uds.to_str().unwrap();
+    let idle_timeout_default = DEFAULT_IDLE_TIMEOUT.as_secs().to_string();
+    let max_connections_default = DEFAULT_MAX_CONNECTIONS.to_string();
+    let workers_default = num_cpus::get().to_string();
+    let queue_default = DEFAULT_QUEUE_LEN.to_string();
+    let job_ttl_default = DEFAULT_JOB_TTL.as_secs().to_string();
+    let max_jobs_default = DEFAULT_MAX_JOBS.to_string();
+    let shutdown_timeout_default = DEFAULT_SHUTDOWN_TIMEOUT.as_secs().to_string();
 
     let matches = App::new(NAME.unwrap())
         .version(VERSION.unwrap())
@@ -37,6 +66,91 @@ fn main() {
                 .help("Output log level")
                 .takes_value(true),
         )
+        .arg(
+            Arg::with_name("preload-bids")
+                .short("p")
+                .long("preload-bids")
+                .value_name("BIDS")
+                .help("Bid list length the generators are created for at startup")
+                .default_value("0")
+                .takes_value(true),
+        )
+        .arg(
+            Arg::with_name("idle-timeout")
+                .short("i")
+                .long("idle-timeout")
+                .value_name("SECONDS")
+                .help("Close connections without requests for this long, 0 to keep them open")
+                .default_value(idle_timeout_default.as_str())
+                .takes_value(true),
+        )
+        .arg(
+            Arg::with_name("max-connections")
+                .short("c")
+                .long("max-connections")
+                .value_name("CONNECTIONS")
+                .help("Connections served at once before the new ones are rejected as busy")
+                .default_value(max_connections_default.as_str())
+                .takes_value(true),
+        )
+        .arg(
+            Arg::with_name("workers")
+                .short("w")
+                .long("workers")
+                .value_name("THREADS")
+                .help("Number of threads proving and verifying")
+                .default_value(workers_default.as_str())
+                .takes_value(true),
+        )
+        .arg(
+            Arg::with_name("queue")
+                .short("q")
+                .long("queue")
+                .value_name("REQUESTS")
+                .help("Requests waiting for a worker before the new ones are rejected as busy")
+                .default_value(queue_default.as_str())
+                .takes_value(true),
+        )
+        .arg(
+            Arg::with_name("job-ttl")
+                .short("t")
+                .long("job-ttl")
+                .value_name("SECONDS")
+                .help("Keep the results of the prove jobs for this long after they are finished")
+                .default_value(job_ttl_default.as_str())
+                .takes_value(true),
+        )
+        .arg(
+            Arg::with_name("max-jobs")
+                .short("j")
+                .long("max-jobs")
+                .value_name("JOBS")
+                .help("Prove jobs kept, finished or not, before the new ones are rejected as busy")
+                .default_value(max_jobs_default.as_str())
+                .takes_value(true),
+        )
+        .arg(
+            Arg::with_name("shutdown-timeout")
+                .short("s")
+                .long("shutdown-timeout")
+                .value_name("SECONDS")
+                .help("On SIGTERM or SIGINT, wait this long for the running requests to finish")
+                .default_value(shutdown_timeout_default.as_str())
+                .takes_value(true),
+        )
+        .arg(
+            Arg::with_name("ready-file")
+                .short("r")
+                .long("ready-file")
+                .value_name("PATH")
+                .help("Create this file, holding the daemon pid, once it answers the requests")
+                .takes_value(true),
+        )
+        .arg(
+            Arg::with_name("ready-stdout")
+                .long("ready-stdout")
+                .help("Print a READY line on stdout once the daemon answers the requests"),
+        )
         .get_matches();
 
     let level = matches
@@ -47,12 +161,180 @@ fn main() {
     }
     env_logger::init();
 
+    let preload: usize = matches
+        .value_of("preload-bids")
+        .expect("Failed parsing preload-bids arg")
+        .parse()
+        .expect("The preload-bids arg must be a number");
+    let capacity = blindbid::generators_capacity(preload).expect("Invalid preload-bids arg");
+
+    // The requests are accepted meanwhile, but the pings fail until the generators are ready
+    thread::spawn(move || {
+        info!("Creating the generators for {} multipliers", capacity);
+        blindbid::preload(capacity);
+        info!("Generators ready");
+    });
+
     let uds = matches
         .value_of("bind-path")
         .expect("Failed parsing bind-path arg");
     let uds = PathBuf::from(String::from(uds));
 
-    UnixDomainSocket::new(uds, None, MainFuture::default())
+    let idle_timeout: u64 = matches
+        .value_of("idle-timeout")
+        .expect("Failed parsing idle-timeout arg")
+        .parse()
+        .expect("The idle-timeout arg must be a number");
+    let idle_timeout = Some(Duration::from_secs(idle_timeout)).filter(|t| t.as_secs() > 0);
+    let max_connections: usize = matches
+        .value_of("max-connections")
+        .expect("Failed parsing max-connections arg")
+        .parse()
+        .expect("The max-connections arg must be a number");
+
+    let workers: usize = matches
+        .value_of("workers")
+        .expect("Failed parsing workers arg")
+        .parse()
+        .expect("The workers arg must be a number");
+    let queue: usize = matches
+        .value_of("queue")
+        .expect("Failed parsing queue arg")
+        .parse()
+        .expect("The queue arg must be a number");
+    let pool = WorkerPool::new(workers, queue).expect("Failed creating the worker pool");
+
+    let job_ttl: u64 = matches
+        .value_of("job-ttl")
+        .expect("Failed parsing job-ttl arg")
+        .parse()
+        .expect("The job-ttl arg must be a number");
+    let max_jobs: usize = matches
+        .value_of("max-jobs")
+        .expect("Failed parsing max-jobs arg")
+        .parse()
+        .expect("The max-jobs arg must be a number");
+    let jobs = JobStore::new(Duration::from_secs(job_ttl), max_jobs);
+
+    info!("Resolving the requests with {} workers", pool.workers());
+    let shutdown_timeout: u64 = matches
+        .value_of("shutdown-timeout")
+        .expect("Failed parsing shutdown-timeout arg")
+        .parse()
+        .expect("The shutdown-timeout arg must be a number");
+    let shutdown_timeout = Duration::from_secs(shutdown_timeout);
+
+    remove_stale_socket(uds.as_path()).expect("Failed checking the bind path");
+
+    let pool = Arc::new(pool);
+    let signals = Signals::new(&[SIGTERM, SIGINT]).expect("Failed registering the signals");
+    {
+        let (uds, pool) = (uds.clone(), Arc::clone(&pool));
+        thread::spawn(move || {
+            if let Some(signal) = signals.forever().next() {
+                shutdown(signal, uds.as_path(), &pool, shutdown_timeout);
+            }
+        });
+    }
+
+    let main = MainFuture::new(idle_timeout, max_connections, pool, Arc::new(jobs));
+
+    let ready_file = matches.value_of("ready-file").map(PathBuf::from);
+    let ready_stdout = matches.is_present("ready-stdout");
+    if ready_file.is_some() || ready_stdout {
+        // A file left by a previous run must not announce this one
+        if let Some(path) = &ready_file {
+            fs::remove_file(path).ok();
+        }
+
+        let uds = uds.clone();
+        thread::spawn(move || notify_ready(uds.as_path(), ready_file, ready_stdout));
+    }
+
+    UnixDomainSocket::new(uds, None, main)
         .bind()
         .expect("Failed binding socket");
 }
+
+/// Wait for the daemon bound to `uds` to answer a ping, then report it is ready.
+fn notify_ready(uds: &Path, ready_file: Option<PathBuf>, ready_stdout: bool) {
+    while !ping(uds).unwrap_or(false) {
+        thread::sleep(READY_POLL);
+    }
+
+    info!("Ready on {}", uds.display());
+
+    if let Some(path) = ready_file {
+        if let Err(e) = fs::write(&path, format!("{}\n", process::id())) {
+            error!("Failed writing the ready file {}: {}", path.display(), e);
+        }
+    }
+
+    if ready_stdout {
+        println!("READY {}", uds.display());
+    }
+}
+
+/// Send a ping to the daemon bound to `uds`, returning whether it succeeded.
+fn ping(uds: &Path) -> Result<bool, Error> {
+    let mut stream = UnixStream::connect(uds)?;
+
+    // No request ID, no timeout and the ping operation code
+    let mut request = vec![0x00u8; 16];
+    request.push(8);
+    TlvWriter::new(&mut stream).write(request.as_slice())?;
+
+    let response = TlvReader::new(&mut stream)
+        .next()
+        .ok_or_else(|| Error::io_unexpected_eof("The ping was not answered"))??;
+
+    Ok(response.get(8) == Some(&STATUS_OK))
+}
+
+/// Remove the socket left at `uds` by a daemon that did not shut down cleanly.
+///
+/// A socket still accepting connections belongs to a running daemon, and anything but a socket
+/// is not ours to remove, so both are reported as errors.
+fn remove_stale_socket(uds: &Path) -> io::Result<()> {
+    let metadata = match fs::symlink_metadata(uds) {
+        Ok(metadata) => metadata,
+        Err(ref e) if e.kind() == io::ErrorKind::NotFound => return Ok(()),
+        Err(e) => return Err(e),
+    };
+
+    if !metadata.file_type().is_socket() {
+        return Err(io::Error::new(
+            io::ErrorKind::AlreadyExists,
+            format!("{} exists and is not a socket", uds.display()),
+        ));
+    }
+
+    match UnixStream::connect(uds) {
+        Ok(_) => Err(io::Error::new(
+            io::ErrorKind::AddrInUse,
+            format!("A daemon is already bound to {}", uds.display()),
+        )),
+        Err(ref e) if e.kind() == io::ErrorKind::ConnectionRefused => {
+            warn!("Removing the stale socket {}", uds.display());
+            fs::remove_file(uds)
+        }
+        Err(e) => Err(e),
+    }
+}
+
+/// Stop accepting connections by removing the socket, let the running requests finish up to
+/// `timeout`, and exit.
+fn shutdown(signal: i32, uds: &Path, pool: &WorkerPool, timeout: Duration) {
+    info!("Shutting down on signal {}", signal);
+
+    if let Err(e) = fs::remove_file(uds) {
+        error!("Failed removing the socket {}: {}", uds.display(), e);
+    }
+
+    let left = pool.drain(timeout);
+    if left > 0 {
+        warn!("Abandoning {} requests", left);
+    }
+
+    process::exit(0);
+}
@@ -0,0 +1,212 @@
+use crate::Error;
+
+use std::panic::{self, AssertUnwindSafe};
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::sync::mpsc::{self, Receiver, SyncSender, TrySendError};
+use std::sync::{Arc, Condvar, Mutex, MutexGuard};
+use std::thread;
+use std::time::{Duration, Instant};
+
+/// Default number of requests waiting for a worker
+pub const DEFAULT_QUEUE_LEN: usize = 64;
+
+type Job = Box<dyn FnOnce() + Send + 'static>;
+
+/// Fixed set of threads resolving the requests, fed by a bounded queue.
+pub struct WorkerPool {
+    workers: usize,
+    queue: SyncSender<Job>,
+    /// Jobs queued or running, signalled whenever one is done
+    pending: Arc<(Mutex<usize>, Condvar)>,
+    draining: AtomicBool,
+}
+
+impl WorkerPool {
+    /// Spawn `workers` threads, accepting up to `queue_len` jobs waiting for them.
+    pub fn new(workers: usize, queue_len: usize) -> Result<Self, Error> {
+        if workers == 0 {
+            return Err(Error::Other(
+                "The worker pool needs at least one worker".to_owned(),
+            ));
+        }
+
+        let (queue, jobs) = mpsc::sync_channel::<Job>(queue_len);
+        let jobs = Arc::new(Mutex::new(jobs));
+
+        for i in 0..workers {
+            let jobs = Arc::clone(&jobs);
+
+            thread::Builder::new()
+                .name(format!("worker-{}", i))
+                .spawn(move || work(jobs))?;
+        }
+
+        Ok(WorkerPool {
+            workers,
+            queue,
+            pending: Arc::new((Mutex::new(0), Condvar::new())),
+            draining: AtomicBool::new(false),
+        })
+    }
+
+    pub fn workers(&self) -> usize {
+        self.workers
+    }
+
+    /// Queue a job, failing with `Error::Busy` instead of waiting when the queue is full, and
+    /// with `Error::ShuttingDown` once the pool is draining.
+    pub fn execute<F: FnOnce() + Send + 'static>(&self, job: F) -> Result<(), Error> {
+        if self.draining.load(Ordering::SeqCst) {
+            return Err(Error::ShuttingDown);
+        }
+
+        *lock_pending(&self.pending) += 1;
+
+        let pending = Arc::clone(&self.pending);
+        let queued = self.queue.try_send(Box::new(move || {
+            // A panicking job must not take its worker down with it
+            if panic::catch_unwind(AssertUnwindSafe(job)).is_err() {
+                error!("A job of the worker pool panicked");
+            }
+
+            *lock_pending(&pending) -= 1;
+            pending.1.notify_all();
+        }));
+
+        queued.map_err(|e| {
+            *lock_pending(&self.pending) -= 1;
+
+            match e {
+                TrySendError::Full(_) => Error::Busy,
+                TrySendError::Disconnected(_) => Error::Other("The worker pool is gone".to_owned()),
+            }
+        })
+    }
+
+    /// Stop accepting jobs, and wait up to `timeout` for the queued and running ones to finish.
+    ///
+    /// Return the number of jobs left unfinished.
+    pub fn drain(&self, timeout: Duration) -> usize {
+        self.draining.store(true, Ordering::SeqCst);
+
+        let deadline = Instant::now() + timeout;
+        let mut pending = lock_pending(&self.pending);
+
+        while *pending > 0 {
+            let now = Instant::now();
+            if now >= deadline {
+                break;
+            }
+
+            pending = match self.pending.1.wait_timeout(pending, deadline - now) {
+                Ok((pending, _)) => pending,
+                Err(e) => e.into_inner().0,
+            };
+        }
+
+        *pending
+    }
+}
+
+impl Default for WorkerPool {
+    fn default() -> Self {
+        WorkerPool::new(num_cpus::get(), DEFAULT_QUEUE_LEN).expect("Failed spawning the workers")
+    }
+}
+
+/// The counter stays usable even if a job panicked while holding it.
+fn lock_pending(pending: &(Mutex<usize>, Condvar)) -> MutexGuard<usize> {
+    pending.0.lock().unwrap_or_else(|e| e.into_inner())
+}
+
+fn work(jobs: Arc<Mutex<Receiver<Job>>>) {
+    loop {
+        // The lock is released as soon as a job is taken
+        let job = match jobs.lock() {
+            Ok(jobs) => jobs.recv(),
+            Err(_) => return,
+        };
+
+        match job {
+            Ok(job) => job(),
+            Err(_) => return,
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    use std::sync::mpsc::channel;
+
+    #[test]
+    fn full_queue_is_busy() {
+        let pool = WorkerPool::new(1, 1).unwrap();
+        let (started, wait_started) = channel();
+        let (release, wait_release) = channel::<()>();
+
+        // Keep the only worker busy
+        pool.execute(move || {
+            started.send(()).unwrap();
+            wait_release.recv().unwrap();
+        })
+        .unwrap();
+        wait_started.recv().unwrap();
+
+        let (done, wait_done) = channel();
+        let queued = done.clone();
+        pool.execute(move || queued.send(1).unwrap()).unwrap();
+
+        match pool.execute(move || done.send(2).unwrap()) {
+            Err(Error::Busy) => (),
+            r => panic!("Unexpected result of a full queue: {:?}", r),
+        }
+
+        release.send(()).unwrap();
+        assert_eq!(1, wait_done.recv().unwrap());
+    }
+
+    #[test]
+    fn panicking_job_keeps_the_worker() {
+        let pool = WorkerPool::new(1, 4).unwrap();
+        let (done, wait_done) = channel();
+
+        pool.execute(|| panic!("malformed request")).unwrap();
+        pool.execute(move || done.send(()).unwrap()).unwrap();
+
+        wait_done.recv_timeout(Duration::from_secs(10)).unwrap();
+        assert_eq!(0, pool.drain(Duration::from_secs(10)));
+    }
+
+    #[test]
+    fn drain_waits_for_the_jobs() {
+        let pool = WorkerPool::new(2, 4).unwrap();
+        let (done, wait_done) = channel();
+
+        for i in 0..4 {
+            let done = done.clone();
+            pool.execute(move || {
+                thread::sleep(Duration::from_millis(50));
+                done.send(i).unwrap();
+            })
+            .unwrap();
+        }
+
+        assert_eq!(0, pool.drain(Duration::from_secs(10)));
+        assert_eq!(4, wait_done.try_iter().count());
+
+        match pool.execute(|| ()) {
+            Err(Error::ShuttingDown) => (),
+            r => panic!("Unexpected result of a draining pool: {:?}", r),
+        }
+
+        // The jobs still running after the timeout are reported
+        let pool = WorkerPool::new(1, 1).unwrap();
+        let (release, wait_release) = channel::<()>();
+        pool.execute(move || wait_release.recv().unwrap()).unwrap();
+
+        assert_eq!(1, pool.drain(Duration::from_millis(10)));
+        release.send(()).unwrap();
+    }
+}
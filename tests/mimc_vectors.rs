@@ -0,0 +1,57 @@
+//! Known-answer vectors shared with the Go `mimc` package.
+
+use std::fs;
+use std::path::PathBuf;
+
+use curve25519_dalek::scalar::Scalar;
+use dusk_blindbidproof::blindbid::{mimc_constants, mimc_hash};
+use dusk_blindbidproof::BidWitness;
+
+fn scalar(s: &str) -> Scalar {
+    assert_eq!(64, s.len(), "malformed scalar {}", s);
+
+    let mut bytes = [0x00u8; 32];
+    for (i, b) in bytes.iter_mut().enumerate() {
+        *b = u8::from_str_radix(&s[2 * i..2 * i + 2], 16).unwrap();
+    }
+
+    Scalar::from_canonical_bytes(bytes).expect("Non canonical scalar")
+}
+
+#[test]
+fn mimc_vectors() {
+    let mut path = PathBuf::from(env!("CARGO_MANIFEST_DIR"));
+    path.push("go/mimc/testdata/vectors.txt");
+
+    let vectors = fs::read_to_string(path).expect("Failed reading the vectors");
+    let constants = mimc_constants();
+
+    for line in vectors.lines() {
+        let v: Vec<&str> = line.split_whitespace().collect();
+
+        match v[0] {
+            "constant" => {
+                let i: usize = v[1].parse().unwrap();
+                assert_eq!(scalar(v[2]), constants[i], "constant {}", i);
+            }
+
+            "hash" => {
+                let h = mimc_hash(&scalar(v[1]), &scalar(v[2]));
+                assert_eq!(scalar(v[3]), h, "{}", line);
+            }
+
+            "derive" => {
+                let w = BidWitness::derive(scalar(v[1]), scalar(v[2]), scalar(v[3]));
+
+                assert_eq!(scalar(v[4]), w.m, "m of {}", line);
+                assert_eq!(scalar(v[5]), w.x, "x of {}", line);
+                assert_eq!(scalar(v[6]), w.y, "y of {}", line);
+                assert_eq!(scalar(v[7]), w.y_inv, "y_inv of {}", line);
+                assert_eq!(scalar(v[8]), w.z, "z of {}", line);
+                assert_eq!(scalar(v[9]), w.q, "q of {}", line);
+            }
+
+            _ => panic!("Unknown vector {}", v[0]),
+        }
+    }
+}
@@ -0,0 +1,117 @@
+//! Frames shared with the Go `tlv` package.
+//!
+//! Run with `TLV_FIXTURES_UPDATE=1` to rewrite go/tlv/testdata/fixtures.txt.
+
+use std::env;
+use std::fs;
+use std::path::PathBuf;
+
+use curve25519_dalek::scalar::Scalar;
+use dusk_tlv::TlvWriter;
+use serde::Serialize;
+
+fn fixtures_path() -> PathBuf {
+    let mut path = PathBuf::from(env!("CARGO_MANIFEST_DIR"));
+    path.push("go/tlv/testdata/fixtures.txt");
+    path
+}
+
+fn frames<F: FnOnce(&mut TlvWriter<Vec<u8>>)>(f: F) -> Vec<u8> {
+    let mut writer = TlvWriter::new(vec![]);
+    f(&mut writer);
+    writer.into_inner()
+}
+
+fn hex(bytes: &[u8]) -> String {
+    bytes.iter().map(|b| format!("{:02x}", b)).collect()
+}
+
+fn fixtures() -> Vec<(&'static str, Vec<u8>)> {
+    let seven = Scalar::from(7u64);
+    let eleven = Scalar::from(11u64);
+
+    vec![
+        (
+            "empty",
+            frames(|w| {
+                w.write(&[]).unwrap();
+            }),
+        ),
+        (
+            "bytes",
+            frames(|w| {
+                w.write(b"dusk").unwrap();
+            }),
+        ),
+        (
+            "u16_length",
+            frames(|w| {
+                w.write(&[0xab; 300]).unwrap();
+            }),
+        ),
+        (
+            "u32_length",
+            frames(|w| {
+                w.write(vec![0x01; 65536].as_slice()).unwrap();
+            }),
+        ),
+        (
+            "list",
+            frames(|w| {
+                w.write_list(&[b"a".to_vec(), b"bc".to_vec(), vec![]])
+                    .unwrap();
+            }),
+        ),
+        (
+            "empty_list",
+            frames(|w| {
+                let empty: Vec<Vec<u8>> = vec![];
+                w.write_list(empty.as_slice()).unwrap();
+            }),
+        ),
+        (
+            "nested_list",
+            frames(|w| {
+                let inner = frames(|i| {
+                    i.write(b"x").unwrap();
+                    i.write(b"yz").unwrap();
+                });
+                w.write_list(&[inner, b"w".to_vec()]).unwrap();
+            }),
+        ),
+        (
+            "scalar",
+            frames(|w| {
+                seven.serialize(w).unwrap();
+            }),
+        ),
+        (
+            "u64",
+            frames(|w| {
+                5u64.serialize(w).unwrap();
+            }),
+        ),
+        (
+            "scalar_list",
+            frames(|w| {
+                w.write_list(&[seven.to_bytes().to_vec(), eleven.to_bytes().to_vec()])
+                    .unwrap();
+            }),
+        ),
+    ]
+}
+
+#[test]
+fn tlv_fixtures() {
+    let encoded: String = fixtures()
+        .iter()
+        .map(|(name, bytes)| format!("{} {}\n", name, hex(bytes)))
+        .collect();
+
+    if env::var_os("TLV_FIXTURES_UPDATE").is_some() {
+        fs::write(fixtures_path(), &encoded).expect("Failed writing the fixtures");
+    }
+
+    let expected = fs::read_to_string(fixtures_path()).expect("Failed reading the fixtures");
+    assert_eq!(expected, encoded);
+}